@@ -17,8 +17,12 @@
 package api
 
 import(
+	"io"
+
 	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/HostPowerMethod"
 	"github.com/CS-SI/LocalDriver/model/enums/HostState"
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
 )
 
 // ClientAPI is an API defining an IaaS driver
@@ -32,6 +36,8 @@ type ClientAPI interface {
 	ListImages(all bool) ([]model.Image, error)
 	// GetImage returns the Image referenced by id
 	GetImage(id string) (*model.Image, error)
+	// CaptureImage creates a new image from the disk of a host
+	CaptureImage(request model.ImageCaptureRequest) (*model.Image, error)
 
 	//GetTemplate returns the Template referenced by id
 	GetTemplate(id string) (*model.HostTemplate, error)
@@ -58,6 +64,28 @@ type ClientAPI interface {
 	ListNetworks() ([]*model.Network, error)
 	// DeleteNetwork deletes the network identified by id
 	DeleteNetwork(id string) error
+	// StartNetwork starts the network identified by id
+	StartNetwork(id string) error
+	// StopNetwork stops the network identified by id, keeping it defined
+	StopNetwork(id string) error
+	// SetNetworkAutostart enables or disables the start of the network identified by id with the hypervisor
+	SetNetworkAutostart(id string, autostart bool) error
+	// PersistNetwork makes the transient network identified by id persistent
+	PersistNetwork(id string) error
+	// IsNetworkPersistent tells if the network identified by id is persistent
+	IsNetworkPersistent(id string) (bool, error)
+	// GetNetworkIPAM returns the subnets and the reserved, static and allocated addresses of the network identified by id
+	GetNetworkIPAM(id string) (*propsv1.NetworkIPAM, error)
+	// ReserveNetworkAddress keeps the address ip of the network identified by id out of use
+	ReserveNetworkAddress(id string, ip string) error
+	// ReserveFreeNetworkAddress reserves a free IPv4 address of the network identified by id and returns it
+	ReserveFreeNetworkAddress(id string) (string, error)
+	// ReleaseNetworkAddress releases the address ip of the network identified by id, reserved by ReserveNetworkAddress
+	ReleaseNetworkAddress(id string, ip string) error
+	// ForwardHypervisorPort forwards a port of the hypervisor to a port of a host of the network identified by networkID
+	ForwardHypervisorPort(networkID string, forward *propsv1.NetworkPortForward) error
+	// RemoveHypervisorPortForward removes a port forward made by ForwardHypervisorPort
+	RemoveHypervisorPortForward(forward *propsv1.NetworkPortForward) error
 	// CreateGateway creates a public Gateway for a private network
 	CreateGateway(req model.GatewayRequest) (*model.Host, error)
 	// DeleteGateway ...
@@ -67,20 +95,54 @@ type ClientAPI interface {
 	CreateHost(request model.HostRequest) (*model.Host, error)
 	// GetHost returns the host identified by id or updates content of a *model.Host
 	GetHost(interface{}) (*model.Host, error)
+	// RefreshHost returns the host identified by id or updates content of a *model.Host, resolving again its IP addresses
+	RefreshHost(interface{}) (*model.Host, error)
 	// GetHostByName returns the host identified by name
 	GetHostByName(string) (*model.Host, error)
 	// DeleteHost deletes the host identified by id
 	DeleteHost(id string) error
 	// ListHosts lists available hosts
 	ListHosts() ([]*model.Host, error)
-	// StopHost stops the host identified by id
-	StopHost(id string) error
+	// StopHost stops the host identified by request.HostID and tells how it has been stopped
+	StopHost(request model.HostStopRequest) (HostPowerMethod.Enum, error)
 	// StartHost starts the host identified by id
 	StartHost(id string) error
-	// Reboot host
-	RebootHost(id string) error
-	// GetHostState returns the current state of the host identified by id
-	GetHostState(hostParam interface{}) (HostState.Enum, error)
+	// RebootHost reboots the host identified by request.HostID and tells how it has been rebooted
+	RebootHost(request model.HostRebootRequest) (HostPowerMethod.Enum, error)
+	// PauseHost suspends the execution of the host identified by id, its memory being kept
+	PauseHost(id string) error
+	// ResumeHost resumes the execution of the host identified by id, paused by PauseHost
+	ResumeHost(id string) error
+	// HibernateHost saves the state of the host identified by id on disk, stops it and returns the hibernated host
+	HibernateHost(id string) (*model.Host, error)
+	// RestoreHost starts the host identified by id from the state saved by HibernateHost
+	RestoreHost(id string) error
+	// OpenHostConsole opens a stream on the serial console of the host identified by id
+	OpenHostConsole(id string) (io.ReadWriteCloser, error)
+	// ResizeHost resizes the host identified by id to fit the template identified by templateID
+	ResizeHost(id string, templateID string) (*model.Host, error)
+	// AttachHostToNetwork plugs a new interface of the host request.HostID on the network request.NetworkID
+	AttachHostToNetwork(request model.HostNetworkRequest) (*model.Host, error)
+	// DetachHostFromNetwork unplugs the interface of the host request.HostID from the network request.NetworkID
+	DetachHostFromNetwork(request model.HostNetworkRequest) (*model.Host, error)
+	// SubscribeEvents calls callback for each lifecycle event of the hosts, networks and storage pools, until unsubscribe is called
+	SubscribeEvents(callback func(event *model.Event)) (unsubscribe func(), err error)
+	// GetHostStats returns the runtime statistics (CPU, memory, disks, network interfaces) of the host identified by id
+	GetHostStats(id string) (*model.HostStats, error)
+	// GetHostState returns the current state of the host identified by id, the description of the state and its reason
+	// given by the hypervisor (ex: "shut off (crashed)") and the reason code of the hypervisor
+	GetHostState(hostParam interface{}) (HostState.Enum, string, int, error)
+
+	// CreateSecurityGroup creates a security group without rule
+	CreateSecurityGroup(request model.SecurityGroupRequest) (*model.SecurityGroup, error)
+	// UpdateSecurityGroup applies the rules of group to the hosts it is applied to
+	UpdateSecurityGroup(group *model.SecurityGroup) error
+	// DeleteSecurityGroup deletes the security group identified by id
+	DeleteSecurityGroup(id string) error
+	// AttachSecurityGroup applies the security group identified by groupID to the host identified by hostID
+	AttachSecurityGroup(hostID string, groupID string) error
+	// DetachSecurityGroup stops applying the security group identified by groupID to the host identified by hostID
+	DetachSecurityGroup(hostID string, groupID string) error
 
 	// CreateVolume creates a block volume
 	// - name is the name of the volume
@@ -93,6 +155,12 @@ type ClientAPI interface {
 	ListVolumes() ([]model.Volume, error)
 	// DeleteVolume deletes the volume identified by id
 	DeleteVolume(id string) error
+	// ResizeVolume grows the volume identified by id to size GB
+	ResizeVolume(id string, size int) (*model.Volume, error)
+	// CreateVolumeSnapshot creates a point-in-time copy of a volume
+	CreateVolumeSnapshot(request model.VolumeSnapshotRequest) (*model.Volume, error)
+	// CreateVolumeClone creates a new volume backed by a snapshot
+	CreateVolumeClone(request model.VolumeCloneRequest) (*model.Volume, error)
 
 	// CreateVolumeAttachment attaches a volume to an host
 	//- name of the volume attachment
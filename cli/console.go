@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// consoleEscape is the byte ending a console session (Ctrl+], as virsh console)
+const consoleEscape byte = 0x1d
+
+// AttachConsole plugs the local terminal, in raw mode, to the console stream until the escape sequence is typed or the stream ends
+func AttachConsole(console io.ReadWriteCloser) error {
+	defer console.Close()
+
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		oldState, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return fmt.Errorf("Failed to set the terminal in raw mode : %s", err.Error())
+		}
+		defer terminal.Restore(fd, oldState)
+	}
+
+	done := make(chan error, 2)
+
+	go func() {
+		_, err := io.Copy(os.Stdout, console)
+		done <- err
+	}()
+
+	go func() {
+		buffer := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buffer)
+			if err != nil {
+				done <- err
+				return
+			}
+			for i := 0; i < n; i++ {
+				if buffer[i] == consoleEscape {
+					if i > 0 {
+						console.Write(buffer[:i])
+					}
+					done <- nil
+					return
+				}
+			}
+			_, err = console.Write(buffer[:n])
+			if err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+
+	err := <-done
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
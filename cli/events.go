@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/CS-SI/LocalDriver/api"
+	"github.com/CS-SI/LocalDriver/metadata"
+	"github.com/CS-SI/LocalDriver/model"
+
+	"github.com/urfave/cli"
+)
+
+// EventsCmd command
+var EventsCmd = cli.Command{
+	Name:  "events",
+	Usage: "Print the lifecycle events of hosts, networks and storage pools",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "follow",
+			Usage: "Keep printing events until interrupted (otherwise exit after the first event)",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print events as JSON lines",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: 0,
+			Usage: "Stop waiting for events after this duration (0 to wait forever)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		// Events are handled outside of the libvirt event loop, saving metadata may take time
+		events := make(chan *model.Event, 64)
+		unsubscribe, err := client.SubscribeEvents(func(event *model.Event) {
+			select {
+			case events <- event:
+			default:
+				fmt.Fprintf(os.Stderr, "Event dropped, too many pending events : %s %s %s\n", event.ResourceType, event.ResourceName, event.Event)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to subscribe to events : %s", err.Error())
+		}
+		defer unsubscribe()
+
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		var timeout <-chan time.Time
+		if c.Duration("timeout") > 0 {
+			timeout = time.After(c.Duration("timeout"))
+		}
+
+		for {
+			select {
+			case event := <-events:
+				err = updateHostState(client, event)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err.Error())
+				}
+				err = displayEvent(event, c.Bool("json"))
+				if err != nil {
+					return err
+				}
+				if !c.Bool("follow") {
+					return nil
+				}
+			case <-timeout:
+				return nil
+			case <-interrupt:
+				return nil
+			}
+		}
+	},
+}
+
+// updateHostState stores in metadata the state of the host concerned by the event, if the host is known in metadata
+func updateHostState(client api.ClientAPI, event *model.Event) error {
+	if event.ResourceType != model.EventHost || event.StateName == "" {
+		return nil
+	}
+	mHost, err := metadata.LoadHost(client, event.ResourceID)
+	if err != nil || mHost == nil {
+		return nil
+	}
+	host := mHost.Get()
+	host.LastState = event.HostState
+	host.LastStateReason = event.StateReason
+	host.LastStateReasonCode = event.StateReasonCode
+	err = metadata.SaveHost(client, host)
+	if err != nil {
+		return fmt.Errorf("Failed to save host '%s' metadata into object storage : %s", host.Name, err.Error())
+	}
+	return nil
+}
+
+func displayEvent(event *model.Event, asJSON bool) error {
+	if asJSON {
+		jsoned, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal event : %s", err.Error())
+		}
+		fmt.Println(string(jsoned))
+		return nil
+	}
+
+	line := fmt.Sprintf("%s	%s	%s	%s", event.Time.Format(time.RFC3339), event.ResourceType, event.ResourceName, event.Event)
+	if event.Detail != "" {
+		line += fmt.Sprintf(" (%s)", event.Detail)
+	}
+	if event.StateName != "" {
+		line += fmt.Sprintf("	-> %s, %s", event.StateName, event.StateReason)
+	}
+	fmt.Println(line)
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/CS-SI/LocalDriver/local"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/urfave/cli"
+)
+
+// ExporterCmd command
+var ExporterCmd = cli.Command{
+	Name:  "exporter",
+	Usage: "Serve Prometheus metrics about the hypervisor and its hosts",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "listen",
+			Value: ":9177",
+			Usage: "Address to listen on",
+		},
+		cli.StringFlag{
+			Name:  "path",
+			Value: "/metrics",
+			Usage: "Path under which the metrics are served",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+		localClient, ok := client.(*local.Client)
+		if !ok {
+			return fmt.Errorf("The exporter needs a local client")
+		}
+
+		registry := prometheus.NewRegistry()
+		err = registry.Register(local.NewCollector(localClient))
+		if err != nil {
+			return fmt.Errorf("Failed to register the collector : %s", err.Error())
+		}
+
+		http.Handle(c.String("path"), promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		fmt.Printf("Serving metrics on %s%s\n", c.String("listen"), c.String("path"))
+		return http.ListenAndServe(c.String("listen"), nil)
+	},
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/CS-SI/LocalDriver/api"
+	"github.com/CS-SI/LocalDriver/metadata"
+	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/utils/retry"
+)
+
+// keepalivedScript installs keepalived on a gateway and makes it share the virtual IP with its peer over unicast VRRP
+// Arguments : own private IP, peer private IP, state, virtual router ID, priority, password, virtual IP in CIDR notation
+const keepalivedScript = `set -e
+# The packages installed by cloud-init hold the package manager lock
+while [ ! -f /var/lib/cloud/instance/boot-finished ]; do sleep 2; done
+if which apt-get >/dev/null 2>&1; then
+	export DEBIAN_FRONTEND=noninteractive
+	apt-get update -q && apt-get install -y -q keepalived
+else
+	yum install -y keepalived
+fi
+
+IFACE=$(ip -o -4 addr show | awk '{ split($4, a, "/"); if (a[1] == "%[1]s") print $2 }')
+[ -n "$IFACE" ]
+
+iptables -C INPUT -p vrrp -s %[2]s -j ACCEPT 2>/dev/null || iptables -I INPUT -p vrrp -s %[2]s -j ACCEPT
+
+cat >/etc/keepalived/keepalived.conf <<EOF
+vrrp_instance virt_gateway {
+	state %[3]s
+	interface $IFACE
+	virtual_router_id %[4]d
+	priority %[5]d
+	advert_int 1
+	unicast_src_ip %[1]s
+	unicast_peer {
+		%[2]s
+	}
+	authentication {
+		auth_type PASS
+		auth_pass %[6]s
+	}
+	virtual_ipaddress {
+		%[7]s dev $IFACE
+	}
+}
+EOF
+systemctl enable keepalived
+systemctl restart keepalived
+`
+
+// networkGatewayIDs returns the IDs of the gateways of a network, the master one first
+func networkGatewayIDs(network *model.Network) []string {
+	ids := []string{}
+	if network.GatewayID != "" {
+		ids = append(ids, network.GatewayID)
+	}
+	if network.SecondaryGatewayID != "" {
+		ids = append(ids, network.SecondaryGatewayID)
+	}
+	return ids
+}
+
+// configureGatewayPair runs keepalived on the primary and the secondary gateways of a network, the primary one
+// holds the virtual IP until it fails
+func configureGatewayPair(network *model.Network, primary *model.Host, secondary *model.Host) error {
+	vip := net.ParseIP(network.GatewayVIP).To4()
+	_, ipNet, err := net.ParseCIDR(network.CIDR)
+	if vip == nil || err != nil {
+		return fmt.Errorf("Invalid virtual IP '%s' for the network %s", network.GatewayVIP, network.CIDR)
+	}
+	prefix, _ := ipNet.Mask.Size()
+	routerID := int(vip[3])%255 + 1
+	password := strings.Replace(network.ID, "-", "", -1)
+	if len(password) > 8 {
+		// keepalived only uses the first 8 characters
+		password = password[:8]
+	}
+
+	pair := []struct {
+		gateway  *model.Host
+		peer     *model.Host
+		state    string
+		priority int
+	}{
+		{primary, secondary, "MASTER", 150},
+		{secondary, primary, "BACKUP", 100},
+	}
+	for _, member := range pair {
+		sshConfig, err := GetSSHConfigFromHostName(member.gateway.ID)
+		if err != nil {
+			return fmt.Errorf("Failed get the sshConfig : %s", err.Error())
+		}
+		script := fmt.Sprintf(keepalivedScript, member.gateway.GetPrivateIP(), member.peer.GetPrivateIP(),
+			member.state, routerID, member.priority, password, fmt.Sprintf("%s/%d", vip.String(), prefix))
+		// The gateway is still booting
+		err = retry.WhileUnsuccessfulDelay1Second(
+			func() error {
+				_, _, _, err := SSHCommandRun("true", sshConfig)
+				return err
+			},
+			5*time.Minute,
+		)
+		if err != nil {
+			return fmt.Errorf("Gateway '%s' is not reachable over SSH : %s", member.gateway.Name, err.Error())
+		}
+		err = runNetworkScript(sshConfig, script+gatewaySaveRulesScript)
+		if err != nil {
+			return fmt.Errorf("Failed to configure keepalived on gateway '%s' : %s", member.gateway.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// createSecondaryGateway creates the secondary gateway of a network and shares a virtual IP between it and the primary
+// gateway, the network is saved with both only once keepalived runs on them, otherwise the secondary gateway is deleted
+func createSecondaryGateway(client api.ClientAPI, network *model.Network, primary *model.Host, request model.GatewayRequest) error {
+	secondary, err := client.CreateGateway(request)
+	if err != nil {
+		return fmt.Errorf("Failed to create the secondary Gateway : %s", err.Error())
+	}
+	err = metadata.SaveHost(client, secondary)
+	if err != nil {
+		return fmt.Errorf("Failed to save gateway metadata into object storage : %s", err.Error())
+	}
+
+	vip, err := client.ReserveFreeNetworkAddress(network.ID)
+	if err == nil {
+		pair := *network
+		pair.SecondaryGatewayID = secondary.ID
+		pair.GatewayVIP = vip
+		err = configureGatewayPair(&pair, primary, secondary)
+		if err == nil {
+			network.SecondaryGatewayID = secondary.ID
+			network.GatewayVIP = vip
+			err = metadata.SaveNetwork(client, network)
+			if err != nil {
+				return fmt.Errorf("Failed to save network metadata into object storage : %s", err.Error())
+			}
+			return nil
+		}
+		err = fmt.Errorf("Failed to configure the highly available gateways : %s", err.Error())
+	} else {
+		err = fmt.Errorf("Failed to reserve the virtual IP of the gateways : %s", err.Error())
+	}
+
+	// The network is left with its primary gateway only
+	fmt.Printf("Deleting the secondary gateway '%s'\n", secondary.Name)
+	if sshConfig, sshErr := GetSSHConfigFromHostName(primary.ID); sshErr == nil {
+		_, _, _, _ = SSHCommandRun("sudo systemctl disable --now keepalived", sshConfig)
+	}
+	if vip != "" {
+		if releaseErr := client.ReleaseNetworkAddress(network.ID, vip); releaseErr != nil {
+			fmt.Printf("Failed to release the virtual IP %s : %s\n", vip, releaseErr.Error())
+		}
+	}
+	if deleteErr := client.DeleteHost(secondary.ID); deleteErr != nil {
+		fmt.Printf("Failed to delete the secondary gateway '%s' : %s\n", secondary.Name, deleteErr.Error())
+	} else if removeErr := metadata.RemoveHost(client, secondary); removeErr != nil {
+		fmt.Printf("Failed to remove gateway '%s' from metadatas : %s\n", secondary.Name, removeErr.Error())
+	}
+	return err
+}
+
+// getGatewayVRRPState tells if the gateway identified by id holds the virtual IP vip (MASTER) or not (BACKUP)
+func getGatewayVRRPState(id string, vip string) string {
+	sshConfig, err := GetSSHConfigFromHostName(id)
+	if err != nil {
+		return "UNKNOWN"
+	}
+	retcode, stdout, _, err := SSHCommandRun("ip -o -4 addr show", sshConfig)
+	if err != nil || retcode != 0 {
+		return "UNREACHABLE"
+	}
+	if strings.Contains(stdout, " "+vip+"/") {
+		return "MASTER"
+	}
+	return "BACKUP"
+}
@@ -17,13 +17,21 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	"github.com/CS-SI/LocalDriver/api"
 	"github.com/CS-SI/LocalDriver/metadata"
 	"github.com/CS-SI/LocalDriver/model"
 	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	"github.com/CS-SI/LocalDriver/model/enums/HostRebootMode"
 	"github.com/CS-SI/LocalDriver/model/enums/IPVersion"
 	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+	propsv2 "github.com/CS-SI/LocalDriver/model/properties/v2"
 
 	"github.com/urfave/cli"
 )
@@ -42,6 +50,15 @@ var HostCmd = cli.Command{
 		hostReboot,
 		hostStatus,
 		hostSsh,
+		hostResize,
+		hostConsole,
+		hostPause,
+		hostResume,
+		hostHibernate,
+		hostRestore,
+		hostStats,
+		hostAttach,
+		hostDetach,
 	},
 }
 
@@ -94,6 +111,11 @@ var hostCreate = cli.Command{
 			Name:  "f, force",
 			Usage: "Force creation even if the host doesn't meet the GPU and CPU freq requirements",
 		},
+		cli.StringFlag{
+			Name:  "ip",
+			Value: "",
+			Usage: "IPv4 address reserved for the host in its network",
+		},
 	},
 	Action: func(c *cli.Context) error {
 
@@ -133,7 +155,7 @@ var hostCreate = cli.Command{
 				networkRequest := model.NetworkRequest{
 					Name:       "net-safescale",
 					IPVersion:  IPVersion.IPv4,
-					CIDR:       "10.0.0.0/24",
+					CIDR:       "",
 					DNSServers: []string{},
 				}
 				net, err = client.CreateNetwork(networkRequest)
@@ -155,7 +177,7 @@ var hostCreate = cli.Command{
 		network := mNetwork.Get()
 
 		var gw *model.Host
-		if c.String("network") != "" {
+		if c.String("network") != "" && network.GatewayID != "" {
 			mGw, err := metadata.LoadHost(client, network.GatewayID)
 			if err != nil || mGw == nil {
 				return fmt.Errorf("Failed to load host '%s' gateway metadatas", networkName)
@@ -171,6 +193,9 @@ var hostCreate = cli.Command{
 			TemplateID:     template.ID,
 			ImageID:        image.ID,
 		}
+		if c.String("ip") != "" {
+			hostRequest.IPAddresses = map[string]string{network.ID: c.String("ip")}
+		}
 
 		host, err := client.CreateHost(hostRequest)
 		if err != nil {
@@ -183,6 +208,10 @@ var hostCreate = cli.Command{
 		if err != nil {
 			return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
 		}
+		_, err = updateNetworkIPAM(client, network.ID)
+		if err != nil {
+			return err
+		}
 
 		displayHost(host)
 
@@ -215,6 +244,11 @@ var hostDelete = cli.Command{
 				return fmt.Errorf("Host '%s' not found in metadatas", hostName)
 			}
 
+			err = removeHostPortForwards(client, mHost.Get())
+			if err != nil {
+				fmt.Printf("Failed to remove the port forwards to host '%s' : %s\n", hostName, err.Error())
+			}
+
 			err = client.DeleteHost(hostName)
 			if err != nil {
 				return fmt.Errorf("Failed to delete '%s' host : %s", hostName, err.Error())
@@ -225,6 +259,19 @@ var hostDelete = cli.Command{
 			if err != nil {
 				return fmt.Errorf("Failed to remove host '%s' from metadatas : %s", hostName, err.Error())
 			}
+			err = removeHostFromSecurityGroups(client, mHost.Get())
+			if err != nil {
+				return err
+			}
+
+			hostNetworkV1 := propsv1.NewHostNetwork()
+			mHost.Get().Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+			for networkID := range hostNetworkV1.NetworksByID {
+				_, err = updateNetworkIPAM(client, networkID)
+				if err != nil {
+					return err
+				}
+			}
 		}
 
 		// TODO check if a host is ready to be destroyed (no volumes ...)
@@ -237,27 +284,61 @@ var hostList = cli.Command{
 	Name:    "list",
 	Aliases: []string{"ls"},
 	Usage:   "List available hosts",
-	Flags:   []cli.Flag{},
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "refresh",
+			Usage: "Resolve again the IP addresses of the hosts instead of using the cached ones",
+		},
+	},
 	Action: func(c *cli.Context) error {
 		client, err := NewClient()
 		if err != nil {
 			return fmt.Errorf("Failed to get a new client : %s", err.Error())
 		}
 
-		var hosts []*model.Host
-		mv := metadata.NewHost(client)
-		err = mv.Browse(func(host *model.Host) error {
-			hosts = append(hosts, host)
+		// Only the hosts known in metadata are listed, the domains give their current state
+		knownIDs := map[string]bool{}
+		mh := metadata.NewHost(client)
+		err = mh.Browse(func(host *model.Host) error {
+			knownIDs[host.ID] = true
 			return nil
 		})
 		if err != nil {
-			return fmt.Errorf("Failed to list volumes: %s", err.Error())
+			return fmt.Errorf("Failed to list hosts : %s", err.Error())
+		}
+		domainHosts, err := client.ListHosts()
+		if err != nil {
+			return fmt.Errorf("Failed to list hosts : %s", err.Error())
+		}
+		hosts := []*model.Host{}
+		for _, host := range domainHosts {
+			if knownIDs[host.ID] {
+				hosts = append(hosts, host)
+			}
+		}
+
+		if c.Bool("refresh") {
+			// The addresses of the hosts are resolved concurrently, each one may wait for its address sources
+			errs := make([]error, len(hosts))
+			var wg sync.WaitGroup
+			for i := range hosts {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					hosts[i], errs[i] = refreshHost(client, hosts[i])
+				}(i)
+			}
+			wg.Wait()
+			for _, err := range errs {
+				if err != nil {
+					return err
+				}
+			}
 		}
 
 		for _, host := range hosts {
 			displayHost(host)
 		}
-
 		return nil
 	},
 }
@@ -267,6 +348,12 @@ var hostInspect = cli.Command{
 	Aliases:   []string{"show"},
 	Usage:     "inspect Host",
 	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "refresh",
+			Usage: "Resolve again the IP addresses of the host instead of using the cached ones",
+		},
+	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 1 {
 			return fmt.Errorf("Missing mandatory argument <Host_name>")
@@ -281,6 +368,12 @@ var hostInspect = cli.Command{
 		if err != nil {
 			return fmt.Errorf("Failed to inspect host '%s' : %s", c.Args().First(), err.Error())
 		}
+		if c.Bool("refresh") {
+			host, err = refreshHost(client, host)
+			if err != nil {
+				return err
+			}
+		}
 		displayHost(host)
 
 		return nil
@@ -314,6 +407,17 @@ var hostStop = cli.Command{
 	Name:      "stop",
 	Usage:     "stop Host",
 	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: 60 * time.Second,
+			Usage: "Time given to the host to stop gracefully (0 to return without waiting)",
+		},
+		cli.BoolFlag{
+			Name:  "f, force",
+			Usage: "Power off the host if it has not stopped before the timeout",
+		},
+	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 1 {
 			return fmt.Errorf("Missing mandatory argument <Host_name>")
@@ -323,12 +427,20 @@ var hostStop = cli.Command{
 		if err != nil {
 			return fmt.Errorf("Failed to get a new client : %s", err.Error())
 		}
-		err = client.StopHost(c.Args().First())
+		method, err := client.StopHost(model.HostStopRequest{
+			HostID:  c.Args().First(),
+			Timeout: c.Duration("timeout"),
+			Force:   c.Bool("force"),
+		})
 		if err != nil {
 			return fmt.Errorf("Failed to stop the host : %s", err.Error())
 		}
+		err = updateHostMetadata(client, c.Args().First())
+		if err != nil {
+			return err
+		}
 
-		fmt.Printf("Host '%s' successfully stopped.\n", c.Args().First())
+		fmt.Printf("Host '%s' successfully stopped (%s).\n", c.Args().First(), method)
 		return nil
 	},
 }
@@ -337,21 +449,47 @@ var hostReboot = cli.Command{
 	Name:      "reboot",
 	Usage:     "reboot Host",
 	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "mode",
+			Value: "acpi",
+			Usage: "Way to reboot the host (acpi, agent or reset)",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: 60 * time.Second,
+			Usage: "Time given to the host to reboot gracefully (0 to return without waiting)",
+		},
+		cli.BoolFlag{
+			Name:  "f, force",
+			Usage: "Reset the host if it has not rebooted before the timeout",
+		},
+	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() != 1 {
 			return fmt.Errorf("Missing mandatory argument <Host_name>")
 		}
 
+		mode, err := HostRebootMode.Parse(c.String("mode"))
+		if err != nil {
+			return err
+		}
+
 		client, err := NewClient()
 		if err != nil {
 			return fmt.Errorf("Failed to get a new client : %s", err.Error())
 		}
-		err = client.RebootHost(c.Args().First())
+		method, err := client.RebootHost(model.HostRebootRequest{
+			HostID:  c.Args().First(),
+			Mode:    mode,
+			Timeout: c.Duration("timeout"),
+			Force:   c.Bool("force"),
+		})
 		if err != nil {
 			return fmt.Errorf("Failed to reboot the host : %s", err.Error())
 		}
 
-		fmt.Printf("Host '%s' successfully rebooted.\n", c.Args().First())
+		fmt.Printf("Host '%s' successfully rebooted (%s).\n", c.Args().First(), method)
 		return nil
 	},
 }
@@ -370,11 +508,11 @@ var hostStatus = cli.Command{
 			return fmt.Errorf("Failed to get a new client : %s", err.Error())
 		}
 
-		hostState, err := client.GetHostState(c.Args().First())
+		hostState, reason, reasonCode, err := client.GetHostState(c.Args().First())
 		if err != nil {
 			return fmt.Errorf("Failed to get host '%s' state : %s", c.Args().First(), err.Error())
 		}
-		fmt.Println(fmt.Sprintf("Host '%s' is in state : %s", c.Args().First(), hostState))
+		fmt.Println(fmt.Sprintf("Host '%s' is in state : %s, %s (reason code %d)", c.Args().First(), hostState, reason, reasonCode))
 
 		return nil
 	},
@@ -400,26 +538,406 @@ var hostSsh = cli.Command{
 	},
 }
 
+var hostResize = cli.Command{
+	Name:      "resize",
+	Usage:     "Resize Host to another template, hot-adding CPUs and RAM when possible",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "template",
+			Value: "",
+			Usage: "Template id (overrides cpu, ram and disk)",
+		},
+		cli.IntFlag{
+			Name:  "cpu",
+			Usage: "Number of CPU for the host, default to the current one",
+		},
+		cli.Float64Flag{
+			Name:  "ram",
+			Usage: "RAM for the host (GB), default to the current one",
+		},
+		cli.IntFlag{
+			Name:  "disk",
+			Usage: "Disk space for the host (GB), default to the current one",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>")
+		}
+		if c.String("template") == "" && !c.IsSet("cpu") && !c.IsSet("ram") && !c.IsSet("disk") {
+			return fmt.Errorf("Missing --template or at least one of --cpu, --ram and --disk")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mHost, err := metadata.LoadHost(client, c.Args().First())
+		if err != nil || mHost == nil {
+			return fmt.Errorf("Host '%s' not found in metadatas", c.Args().First())
+		}
+
+		var template *model.HostTemplate
+		if c.String("template") != "" {
+			template, err = client.GetTemplate(c.String("template"))
+			if err != nil {
+				return fmt.Errorf("Failed to get the template : %s", err.Error())
+			}
+		} else {
+			templates, err := client.ListTemplates(true)
+			if err != nil {
+				return fmt.Errorf("Failed to get the templates : %s", err.Error())
+			}
+			// The sizes which are not given are kept
+			hostSizingV1 := propsv1.NewHostSizing()
+			err = mHost.Get().Properties.Get(HostProperty.SizingV1, hostSizingV1)
+			if err != nil {
+				return fmt.Errorf("Failed to get host '%s' sizing : %s", c.Args().First(), err.Error())
+			}
+			sizingRequirements := model.SizingRequirements{
+				MinCores:    hostSizingV1.RequestedSize.Cores,
+				MinRAMSize:  hostSizingV1.RequestedSize.RAMSize / 1024,
+				MinDiskSize: hostSizingV1.RequestedSize.DiskSize,
+			}
+			if c.IsSet("cpu") {
+				sizingRequirements.MinCores = c.Int("cpu")
+			}
+			if c.IsSet("ram") {
+				sizingRequirements.MinRAMSize = float32(c.Float64("ram"))
+			}
+			if c.IsSet("disk") {
+				sizingRequirements.MinDiskSize = c.Int("disk")
+			}
+			template, err = SelectTemplateBySize(sizingRequirements, templates)
+			if err != nil {
+				return fmt.Errorf("Failed to select template by size : %s", err.Error())
+			}
+		}
+
+		resizedHost, err := client.ResizeHost(c.Args().First(), template.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to resize host '%s' : %s", c.Args().First(), err.Error())
+		}
+
+		host := mHost.Get()
+		hostSizingV1 := propsv1.NewHostSizing()
+		resizedHost.Properties.Get(HostProperty.SizingV1, hostSizingV1)
+		host.Properties.Set(HostProperty.SizingV1, hostSizingV1)
+		host.LastState = resizedHost.LastState
+		err = metadata.SaveHost(client, host)
+		if err != nil {
+			return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+		}
+
+		displayHost(host)
+
+		return nil
+	},
+}
+
+var hostConsole = cli.Command{
+	Name:      "console",
+	Usage:     "Connect to the serial console of the host (exit with Ctrl+])",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		console, err := client.OpenHostConsole(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to open the console of host '%s' : %s", c.Args().First(), err.Error())
+		}
+
+		fmt.Printf("Connected to the console of host '%s' (escape character is ^])\r\n", c.Args().First())
+		err = AttachConsole(console)
+		if err != nil {
+			return fmt.Errorf("Console session of host '%s' failed : %s", c.Args().First(), err.Error())
+		}
+		fmt.Println()
+
+		return nil
+	},
+}
+
+var hostPause = cli.Command{
+	Name:      "pause",
+	Usage:     "Pause Host, keeping its memory",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+		err = client.PauseHost(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to pause the host : %s", err.Error())
+		}
+		err = updateHostMetadata(client, c.Args().First())
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Host '%s' successfully paused.\n", c.Args().First())
+		return nil
+	},
+}
+
+var hostResume = cli.Command{
+	Name:      "resume",
+	Usage:     "Resume paused Host",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+		err = client.ResumeHost(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to resume the host : %s", err.Error())
+		}
+		err = updateHostMetadata(client, c.Args().First())
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Host '%s' successfully resumed.\n", c.Args().First())
+		return nil
+	},
+}
+
+var hostHibernate = cli.Command{
+	Name:      "hibernate",
+	Usage:     "Save the state of Host on disk and stop it",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+		host, err := client.HibernateHost(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to hibernate the host : %s", err.Error())
+		}
+		mHost, err := metadata.LoadHost(client, host.ID)
+		if err == nil && mHost != nil {
+			err = metadata.SaveHost(client, host)
+			if err != nil {
+				return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+			}
+		}
+
+		fmt.Printf("Host '%s' successfully hibernated.\n", c.Args().First())
+		return nil
+	},
+}
+
+var hostRestore = cli.Command{
+	Name:      "restore",
+	Usage:     "Start Host from the state saved by hibernate",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+		err = client.RestoreHost(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to restore the host : %s", err.Error())
+		}
+		err = updateHostMetadata(client, c.Args().First())
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Host '%s' successfully restored.\n", c.Args().First())
+		return nil
+	},
+}
+
+var hostStats = cli.Command{
+	Name:      "stats",
+	Usage:     "Display the runtime statistics of Host",
+	ArgsUsage: "<Host_name|Host_ID>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "watch",
+			Usage: "Refresh the statistics until interrupted",
+		},
+		cli.DurationFlag{
+			Name:  "interval",
+			Value: 2 * time.Second,
+			Usage: "Time between two samples",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Print each sample as a JSON line",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		// The CPU utilisation is computed between two samples
+		previous, err := client.GetHostStats(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to get host '%s' statistics : %s", c.Args().First(), err.Error())
+		}
+		for {
+			time.Sleep(c.Duration("interval"))
+			stats, err := client.GetHostStats(c.Args().First())
+			if err != nil {
+				return fmt.Errorf("Failed to get host '%s' statistics : %s", c.Args().First(), err.Error())
+			}
+			stats.ComputeCPUUtilisation(previous)
+			previous = stats
+
+			if c.Bool("json") {
+				jsoned, err := json.Marshal(stats)
+				if err != nil {
+					return fmt.Errorf("Failed to marshal statistics : %s", err.Error())
+				}
+				fmt.Println(string(jsoned))
+			} else {
+				if c.Bool("watch") {
+					// Clears the terminal to refresh the table in place
+					fmt.Print("\033[H\033[2J")
+				}
+				displayHostStats(stats)
+			}
+
+			if !c.Bool("watch") {
+				return nil
+			}
+		}
+	},
+}
+
+func displayHostStats(stats *model.HostStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Host : %s (%s)	%s\n", stats.HostName, stats.HostID, stats.Time.Format(time.RFC3339))
+	fmt.Fprintf(w, "CPU\tvCPUs\tUtilisation\tTime\n")
+	fmt.Fprintf(w, "\t%d\t%.1f%%\t%s\n", stats.VCPUs, stats.CPUUtilisation, time.Duration(stats.CPUTime))
+	fmt.Fprintf(w, "Memory\tBalloon (MiB)\tMaximum (MiB)\tRSS (MiB)\tUnused (MiB)\n")
+	fmt.Fprintf(w, "\t%d\t%d\t%d\t%d\n", stats.MemoryBalloon/1024, stats.MemoryMaximum/1024, stats.MemoryRSS/1024, stats.MemoryUnused/1024)
+	fmt.Fprintf(w, "Disk\tRead (bytes)\tRead (ops)\tWritten (bytes)\tWritten (ops)\n")
+	for _, disk := range stats.Disks {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", disk.Name, disk.ReadBytes, disk.ReadOps, disk.WriteBytes, disk.WriteOps)
+	}
+	fmt.Fprintf(w, "Interface\tRx (bytes)\tRx (packets)\tTx (bytes)\tTx (packets)\n")
+	for _, iface := range stats.Interfaces {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\n", iface.Name, iface.RxBytes, iface.RxPackets, iface.TxBytes, iface.TxPackets)
+	}
+	w.Flush()
+}
+
+// updateHostMetadata stores the current state of a host in metadata, if the host is known in metadata
+func updateHostMetadata(client api.ClientAPI, ref string) error {
+	mHost, err := metadata.LoadHost(client, ref)
+	if err != nil || mHost == nil {
+		return nil
+	}
+	host, err := client.GetHost(mHost.Get().ID)
+	if err != nil {
+		return fmt.Errorf("Failed to get host '%s' : %s", ref, err.Error())
+	}
+	err = metadata.SaveHost(client, host)
+	if err != nil {
+		return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+	}
+	return nil
+}
+
+// refreshHost resolves again the IP addresses of a host and, if the host is known in metadata, caches them
+func refreshHost(client api.ClientAPI, host *model.Host) (*model.Host, error) {
+	refreshedHost, err := client.RefreshHost(host)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to refresh host '%s' : %s", host.Name, err.Error())
+	}
+	mHost, err := metadata.LoadHost(client, host.ID)
+	if err == nil && mHost != nil {
+		err = metadata.SaveHost(client, refreshedHost)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+		}
+	}
+	return refreshedHost, nil
+}
+
 func displayHost(host *model.Host) {
 	hostNetworkV1 := propsv1.NewHostNetwork()
 	hostSizingV1 := propsv1.NewHostSizing()
 	hostVolumesV1 := propsv1.NewHostVolumes()
 	hostMountsV1 := propsv1.NewHostMounts()
+	hostNetworkV2 := propsv2.NewHostNetwork()
+	hostHibernationV1 := propsv1.NewHostHibernation()
+	hostSecurityGroupsV1 := propsv1.NewHostSecurityGroups()
 
 	host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
 	host.Properties.Get(HostProperty.SizingV1, hostSizingV1)
 	host.Properties.Get(HostProperty.VolumesV1, hostVolumesV1)
 	host.Properties.Get(HostProperty.MountsV1, hostMountsV1)
+	host.Properties.Get(HostProperty.NetworkV2, hostNetworkV2)
+	host.Properties.Get(HostProperty.HibernationV1, hostHibernationV1)
+	host.Properties.Get(HostProperty.SecurityGroupsV1, hostSecurityGroupsV1)
 
 	fmt.Println("\nHost : ", host.Name)
 	fmt.Println("	ID	: ", host.ID)
-	fmt.Println("	State 	: ", host.LastState)
+	fmt.Println("	State 	: ", host.LastState, host.LastStateReason)
+	if hostHibernationV1.StateFile != "" {
+		fmt.Println("	Hibernated :")
+		if !hostHibernationV1.SavedAt.IsZero() {
+			fmt.Println("		Saved at	: ", hostHibernationV1.SavedAt.Format(time.RFC3339))
+		}
+		fmt.Println("		State file	: ", hostHibernationV1.StateFile)
+	}
 	fmt.Println("	Network :")
 	fmt.Println("		Is Gateway 	: ", hostNetworkV1.IsGateway)
 	fmt.Println("		Public IP	: ", hostNetworkV1.PublicIPv4)
 	fmt.Println("		Private IP	: ", hostNetworkV1.IPv4Addresses[hostNetworkV1.DefaultNetworkID])
+	if ipv6, ok := hostNetworkV1.IPv6Addresses[hostNetworkV1.DefaultNetworkID]; ok {
+		fmt.Println("		Private IPv6	: ", ipv6)
+	}
 	fmt.Println("		Network		: ", hostNetworkV1.NetworksByID[hostNetworkV1.DefaultNetworkID])
 	fmt.Println("		Gateway ID	: ", hostNetworkV1.DefaultGatewayID)
+	if !hostNetworkV2.ResolvedAt.IsZero() {
+		fmt.Println("		Resolved at	: ", hostNetworkV2.ResolvedAt.Format(time.RFC3339))
+	}
+	if len(hostSecurityGroupsV1.ByName) > 0 {
+		fmt.Println("	Security groups :")
+		for name := range hostSecurityGroupsV1.ByName {
+			fmt.Println("		", name)
+		}
+	}
 	fmt.Println("	Sizing :")
 	fmt.Println("		Cores	:", hostSizingV1.AllocatedSize.Cores)
 	fmt.Println("		Ram 	:", hostSizingV1.AllocatedSize.RAMSize)
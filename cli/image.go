@@ -29,6 +29,7 @@ var ImageCmd = cli.Command{
 	Usage: "image COMMAND",
 	Subcommands: []cli.Command{
 		imageList,
+		imageCapture,
 	},
 }
 
@@ -55,6 +56,45 @@ var imageList = cli.Command{
 	},
 }
 
+var imageCapture = cli.Command{
+	Name:      "capture",
+	Usage:     "Capture the disk of a host as a new image",
+	ArgsUsage: "<Host_name|Host_ID> <Image_name>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "shutdown",
+			Usage: "Stop the host during the capture (restarted afterwards)",
+		},
+		cli.BoolFlag{
+			Name:  "sysprep",
+			Usage: "Reset machine-id, SSH host keys and userdata artifacts of the captured disk",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			return fmt.Errorf("Missing mandatory argument <Host_name> and/or <Image_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		image, err := client.CaptureImage(model.ImageCaptureRequest{
+			HostID:   c.Args().Get(0),
+			Name:     c.Args().Get(1),
+			Shutdown: c.Bool("shutdown"),
+			Sysprep:  c.Bool("sysprep"),
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to capture host '%s' : %s", c.Args().Get(0), err.Error())
+		}
+
+		displayImage(image)
+		return nil
+	},
+}
+
 func displayImage(image *model.Image) {
 	fmt.Println("\nTemplate :", image.Name)
 	fmt.Println("	ID	:", image.ID)
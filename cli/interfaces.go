@@ -0,0 +1,339 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CS-SI/LocalDriver/metadata"
+	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/HostState"
+	"github.com/CS-SI/LocalDriver/system"
+	"github.com/CS-SI/LocalDriver/utils/retry"
+
+	"github.com/urfave/cli"
+)
+
+// interfaceUpScript persists the configuration of a new interface (DHCP) and brings it up, whatever the way the
+// network of the host is managed (see the configure_network_* functions of the userdata)
+const interfaceUpScript = `IF=%s
+if [ -d /etc/sysconfig/network-scripts ]; then
+    cat >/etc/sysconfig/network-scripts/ifcfg-$IF <<EOF
+DEVICE=$IF
+BOOTPROTO=dhcp
+ONBOOT=yes
+DEFROUTE=no
+PEERDNS=no
+EOF
+    ifup $IF
+elif [ -d /etc/netplan ] && systemctl status systemd-networkd &>/dev/null; then
+    cat >/etc/netplan/60-$IF.yaml <<EOF
+network:
+  version: 2
+  renderer: networkd
+  ethernets:
+    $IF:
+      dhcp4: true
+      dhcp4-overrides:
+        use-routes: false
+        use-dns: false
+EOF
+    netplan generate && netplan apply
+else
+    mkdir -p /etc/network/interfaces.d
+    echo -e "auto $IF\niface $IF inet dhcp" >/etc/network/interfaces.d/60-$IF.cfg
+    ifup $IF
+fi
+`
+
+// interfaceDownScript removes the configuration written by interfaceUpScript for the interfaces which vanished
+const interfaceDownScript = `for CFG in /etc/sysconfig/network-scripts/ifcfg-* /etc/netplan/60-*.yaml /etc/network/interfaces.d/60-*.cfg; do
+    [ -f $CFG ] || continue
+    IF=$(basename $CFG | sed -e 's/^ifcfg-//' -e 's/^60-//' -e 's/\.yaml$//' -e 's/\.cfg$//')
+    [ "$IF" = "lo" ] || [ -e /sys/class/net/$IF ] || rm -f $CFG
+done
+[ -d /etc/netplan ] && systemctl status systemd-networkd &>/dev/null && netplan generate && netplan apply
+exit 0
+`
+
+var hostAttach = cli.Command{
+	Name:      "attach",
+	Usage:     "Plug a host on a network, the new interface is brought up if the host is started",
+	ArgsUsage: "<Host_name|Host_ID> <Network_name|Network_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "ip",
+			Value: "",
+			Usage: "IPv4 address to reserve for the host on the network, leased by the DHCP if empty",
+		},
+		cli.StringFlag{
+			Name:  "vlan",
+			Value: "",
+			Usage: "VLAN tag of the port of the host replacing the ones of an Open vSwitch network, several comma separated tags make a trunk port",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			return fmt.Errorf("Missing mandatory arguments <Host_name> <Network_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mHost, err := metadata.LoadHost(client, c.Args().Get(0))
+		if err != nil || mHost == nil {
+			return fmt.Errorf("Host '%s' not found in metadatas", c.Args().Get(0))
+		}
+		host := mHost.Get()
+		mNetwork, err := metadata.LoadNetwork(client, c.Args().Get(1))
+		if err != nil || mNetwork == nil {
+			return fmt.Errorf("Network '%s' not found in metadatas", c.Args().Get(1))
+		}
+		network := mNetwork.Get()
+		vlans, err := parseVLANs(c.String("vlan"))
+		if err != nil {
+			return err
+		}
+
+		hostState, _, _, err := client.GetHostState(host.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to get host '%s' state : %s", host.Name, err.Error())
+		}
+		var sshConfig *system.SSHConfig
+		var oldInterfaces []string
+		if hostState == HostState.STARTED {
+			sshConfig, err = GetSSHConfigFromHostName(host.ID)
+			if err != nil {
+				return fmt.Errorf("Failed get the sshConfig : %s", err.Error())
+			}
+			oldInterfaces, err = listNetworkInterfaces(sshConfig)
+			if err != nil {
+				return fmt.Errorf("Failed to get list of network interfaces : %s", err.Error())
+			}
+		}
+
+		host, err = client.AttachHostToNetwork(model.HostNetworkRequest{
+			HostID:    host.ID,
+			NetworkID: network.ID,
+			IPAddress: c.String("ip"),
+			VLANs:     vlans,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to plug host '%s' on network '%s' : %s", c.Args().Get(0), c.Args().Get(1), err.Error())
+		}
+		fmt.Println(fmt.Sprintf("Host '%s' successfully plugged on network '%s'", host.Name, network.Name))
+
+		// The interface is plugged, the metadatas have to follow whatever happens inside the host
+		err = metadata.SaveHost(client, host)
+		if err != nil {
+			return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+		}
+		err = mNetwork.AttachHost(host)
+		if err != nil {
+			return fmt.Errorf("Failed to attach host '%s' to network '%s' in metadatas : %s", host.Name, network.Name, err.Error())
+		}
+		err = mNetwork.Write()
+		if err != nil {
+			return fmt.Errorf("Failed to save network metadatas : %s", err.Error())
+		}
+
+		if sshConfig != nil {
+			var newInterfaces []string
+			// The hot-plugged interface takes a few moments to show up inside the host
+			err = retry.WhileUnsuccessfulDelay1Second(
+				func() error {
+					newInterfaces, err = listNetworkInterfaces(sshConfig)
+					if err != nil {
+						return err
+					}
+					if len(difference(oldInterfaces, newInterfaces)) == 0 {
+						return fmt.Errorf("No new network interface")
+					}
+					return nil
+				},
+				30*time.Second,
+			)
+			if err != nil {
+				return fmt.Errorf("Failed to find the new network interface inside host '%s' : %s", host.Name, err.Error())
+			}
+			diff := difference(oldInterfaces, newInterfaces)
+			if len(diff) != 1 {
+				return fmt.Errorf("Failed to find the new network interface inside host '%s'", host.Name)
+			}
+
+			err = runNetworkScript(sshConfig, fmt.Sprintf(interfaceUpScript, diff[0]))
+			if err != nil {
+				return fmt.Errorf("Failed to bring up interface '%s' : %s", diff[0], err.Error())
+			}
+
+			refreshedHost, err := client.RefreshHost(host)
+			if err != nil {
+				return fmt.Errorf("Failed to refresh host '%s' : %s", host.Name, err.Error())
+			}
+			host = refreshedHost
+			err = metadata.SaveHost(client, host)
+			if err != nil {
+				return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+			}
+		}
+
+		_, err = updateNetworkIPAM(client, network.ID)
+		if err != nil {
+			return err
+		}
+
+		displayHost(host)
+
+		return nil
+	},
+}
+
+var hostDetach = cli.Command{
+	Name:      "detach",
+	Usage:     "Unplug a host from a network which is not its default one",
+	ArgsUsage: "<Host_name|Host_ID> <Network_name|Network_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			return fmt.Errorf("Missing mandatory arguments <Host_name> <Network_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mHost, err := metadata.LoadHost(client, c.Args().Get(0))
+		if err != nil || mHost == nil {
+			return fmt.Errorf("Host '%s' not found in metadatas", c.Args().Get(0))
+		}
+		host := mHost.Get()
+		mNetwork, err := metadata.LoadNetwork(client, c.Args().Get(1))
+		if err != nil || mNetwork == nil {
+			return fmt.Errorf("Network '%s' not found in metadatas", c.Args().Get(1))
+		}
+		network := mNetwork.Get()
+
+		hostState, _, _, err := client.GetHostState(host.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to get host '%s' state : %s", host.Name, err.Error())
+		}
+		var sshConfig *system.SSHConfig
+		var oldInterfaces []string
+		if hostState == HostState.STARTED {
+			sshConfig, err = GetSSHConfigFromHostName(host.ID)
+			if err != nil {
+				return fmt.Errorf("Failed get the sshConfig : %s", err.Error())
+			}
+			oldInterfaces, err = listNetworkInterfaces(sshConfig)
+			if err != nil {
+				return fmt.Errorf("Failed to get list of network interfaces : %s", err.Error())
+			}
+		}
+
+		host, err = client.DetachHostFromNetwork(model.HostNetworkRequest{
+			HostID:    host.ID,
+			NetworkID: network.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to unplug host '%s' from network '%s' : %s", c.Args().Get(0), c.Args().Get(1), err.Error())
+		}
+		fmt.Println(fmt.Sprintf("Host '%s' successfully unplugged from network '%s'", host.Name, network.Name))
+
+		// The interface is unplugged, the metadatas have to follow whatever happens inside the host
+		err = metadata.SaveHost(client, host)
+		if err != nil {
+			return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+		}
+		err = mNetwork.DetachHost(host.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to detach host '%s' from network '%s' in metadatas : %s", host.Name, network.Name, err.Error())
+		}
+		err = mNetwork.Write()
+		if err != nil {
+			return fmt.Errorf("Failed to save network metadatas : %s", err.Error())
+		}
+
+		if sshConfig != nil {
+			// The guest releases the hot-unplugged interface a few moments after libvirt returns
+			err = retry.WhileUnsuccessfulDelay1Second(
+				func() error {
+					newInterfaces, err := listNetworkInterfaces(sshConfig)
+					if err != nil {
+						return err
+					}
+					if len(difference(oldInterfaces, newInterfaces)) == 0 {
+						return fmt.Errorf("No network interface removed")
+					}
+					return nil
+				},
+				30*time.Second,
+			)
+			if err != nil {
+				return fmt.Errorf("Failed to see the unplugged network interface disappear inside host '%s' : %s", host.Name, err.Error())
+			}
+
+			err = runNetworkScript(sshConfig, interfaceDownScript)
+			if err != nil {
+				return fmt.Errorf("Failed to remove the configuration of the unplugged interface : %s", err.Error())
+			}
+		}
+
+		_, err = updateNetworkIPAM(client, network.ID)
+		if err != nil {
+			return err
+		}
+
+		displayHost(host)
+
+		return nil
+	},
+}
+
+// listNetworkInterfaces returns the names of the network interfaces of a host, except the loopback
+func listNetworkInterfaces(sshConfig *system.SSHConfig) ([]string, error) {
+	interfaces := []string{}
+	retcode, stdout, stderr, err := SSHCommandRun("ls /sys/class/net", sshConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run the command : %s", err.Error())
+	} else if retcode != 0 {
+		return nil, fmt.Errorf("Command did not finish properly : %s", stderr)
+	}
+
+	for _, iface := range strings.Fields(stdout) {
+		if iface != "lo" {
+			interfaces = append(interfaces, iface)
+		}
+	}
+	return interfaces, nil
+}
+
+// runNetworkScript runs script as root on a host
+func runNetworkScript(sshConfig *system.SSHConfig, script string) error {
+	command := fmt.Sprintf("echo %s | base64 -d | sudo bash", base64.StdEncoding.EncodeToString([]byte(script)))
+	retcode, _, stderr, err := SSHCommandRun(command, sshConfig)
+	if err != nil {
+		return fmt.Errorf("Failed to run the command : %s", err.Error())
+	} else if retcode != 0 {
+		return fmt.Errorf("Command did not finish properly : %s", stderr)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/CS-SI/LocalDriver/api"
+	"github.com/CS-SI/LocalDriver/metadata"
+	"github.com/CS-SI/LocalDriver/model/enums/NetworkProperty"
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+
+	"github.com/urfave/cli"
+)
+
+var networkIP = cli.Command{
+	Name:  "ip",
+	Usage: "ip COMMAND",
+	Subcommands: []cli.Command{
+		networkIPList,
+		networkIPReserve,
+		networkIPRelease,
+	},
+}
+
+var networkIPList = cli.Command{
+	Name:      "list",
+	Aliases:   []string{"ls"},
+	Usage:     "List the subnets and the used addresses of a network",
+	ArgsUsage: "<network_name>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("Missing mandatory argument <Network_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		ipam, err := updateNetworkIPAM(client, c.Args().First())
+		if err != nil {
+			return err
+		}
+
+		displayNetworkIPAM(ipam)
+
+		return nil
+	},
+}
+
+var networkIPReserve = cli.Command{
+	Name:      "reserve",
+	Usage:     "Keep an IPv4 address of a network out of use",
+	ArgsUsage: "<network_name> <ip>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 2 {
+			return fmt.Errorf("Missing mandatory arguments <Network_name> <ip>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		err = client.ReserveNetworkAddress(c.Args().Get(0), c.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("Failed to reserve '%s' : %s", c.Args().Get(1), err.Error())
+		}
+		fmt.Println(fmt.Sprintf("Address '%s' successfully reserved", c.Args().Get(1)))
+
+		_, err = updateNetworkIPAM(client, c.Args().Get(0))
+		return err
+	},
+}
+
+var networkIPRelease = cli.Command{
+	Name:      "release",
+	Usage:     "Release an address of a network reserved by 'ip reserve'",
+	ArgsUsage: "<network_name> <ip>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 2 {
+			return fmt.Errorf("Missing mandatory arguments <Network_name> <ip>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		err = client.ReleaseNetworkAddress(c.Args().Get(0), c.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("Failed to release '%s' : %s", c.Args().Get(1), err.Error())
+		}
+		fmt.Println(fmt.Sprintf("Address '%s' successfully released", c.Args().Get(1)))
+
+		_, err = updateNetworkIPAM(client, c.Args().Get(0))
+		return err
+	},
+}
+
+// updateNetworkIPAM gets the addresses management of a network and stores it in metadata, if the network is known in metadata
+func updateNetworkIPAM(client api.ClientAPI, ref string) (*propsv1.NetworkIPAM, error) {
+	ipam, err := client.GetNetworkIPAM(ref)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the addresses of network '%s' : %s", ref, err.Error())
+	}
+
+	mNetwork, err := metadata.LoadNetwork(client, ref)
+	if err != nil || mNetwork == nil {
+		return ipam, nil
+	}
+	network := mNetwork.Get()
+	network.Properties.Set(NetworkProperty.IPAMV1, ipam)
+	err = metadata.SaveNetwork(client, network)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to save network '%s' metadata : %s", ref, err.Error())
+	}
+
+	return ipam, nil
+}
+
+func displayNetworkIPAM(ipam *propsv1.NetworkIPAM) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Subnet\tGateway\tDHCP pool\n")
+	for _, subnet := range ipam.Subnets {
+		pool := "-"
+		if subnet.PoolStart != "" {
+			pool = subnet.PoolStart + " - " + subnet.PoolEnd
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", subnet.CIDR, subnet.Gateway, pool)
+	}
+	w.Flush()
+
+	// An address reserved for a host is shown as static, even when it is leased
+	states := map[string]string{}
+	macs := map[string]string{}
+	for ip, mac := range ipam.Allocated {
+		states[ip], macs[ip] = "allocated", mac
+	}
+	for ip, mac := range ipam.Reserved {
+		states[ip], macs[ip] = "reserved", mac
+	}
+	for ip, mac := range ipam.Static {
+		states[ip], macs[ip] = "static", mac
+	}
+	addresses := []string{}
+	for ip := range states {
+		addresses = append(addresses, ip)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(addresses[i]).To16(), net.ParseIP(addresses[j]).To16()) < 0
+	})
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Address\tState\tMAC\n")
+	for _, ip := range addresses {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ip, states[ip], macs[ip])
+	}
+	w.Flush()
+	fmt.Println("\nUpdated at : ", ipam.UpdatedAt.Format(time.RFC3339))
+}
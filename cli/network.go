@@ -18,10 +18,14 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/CS-SI/LocalDriver/metadata"
 	"github.com/CS-SI/LocalDriver/model"
 	"github.com/CS-SI/LocalDriver/model/enums/IPVersion"
+	"github.com/CS-SI/LocalDriver/model/enums/IPv6Mode"
+	"github.com/CS-SI/LocalDriver/model/enums/NetworkMode"
 
 	"github.com/urfave/cli"
 )
@@ -35,6 +39,11 @@ var NetworkCmd = cli.Command{
 		networkDelete,
 		networkList,
 		networkInspect,
+		networkStart,
+		networkStop,
+		networkAutostart,
+		networkPersist,
+		networkIP,
 	},
 }
 
@@ -48,8 +57,42 @@ var networkCreate = cli.Command{
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "cidr",
-			Value: "192.168.0.0/24",
-			Usage: "cidr of the network",
+			Value: "",
+			Usage: "cidr of the network, an IPv6 cidr creates an IPv6 only network. Default to a free subnet of the supernet pool",
+		},
+		cli.StringFlag{
+			Name:  "cidr6",
+			Value: "",
+			Usage: "IPv6 prefix added to an IPv4 network to make it dual-stack",
+		},
+		cli.StringFlag{
+			Name:  "ipv6-mode",
+			Value: "dhcp",
+			Usage: "how the hosts get their IPv6 addresses : dhcp or slaac (needs a /64 prefix)",
+		},
+		cli.StringFlag{
+			Name:  "mode",
+			Value: "isolated",
+			Usage: "how the traffic of the network is forwarded outside of the hypervisor : isolated, nat, route, open or bridge",
+		},
+		cli.StringFlag{
+			Name:  "bridge",
+			Value: "",
+			Usage: "existing Linux or Open vSwitch bridge of the hypervisor a network in bridge mode is plugged on, implies --mode bridge",
+		},
+		cli.StringFlag{
+			Name:  "vlan",
+			Value: "",
+			Usage: "VLAN tag of the ports of the hosts on an Open vSwitch bridge, several comma separated tags make trunk ports (like 120,130)",
+		},
+		cli.StringSliceFlag{
+			Name:  "dns",
+			Usage: "Upstream DNS server the requests are forwarded to (can be repeated)",
+		},
+		cli.StringFlag{
+			Name:  "domain",
+			Value: "",
+			Usage: "DNS domain of the hosts of the network. Default to '<network_name>.local'",
 		},
 		cli.IntFlag{
 			Name:  "cpu",
@@ -76,6 +119,10 @@ var networkCreate = cli.Command{
 			Value: "",
 			Usage: "Name for the gateway. Default to 'gw-<network_name>'",
 		},
+		cli.BoolFlag{
+			Name:  "ha",
+			Usage: "Deploy a primary and a secondary gateway sharing a virtual IP with keepalived (IPv4 networks only)",
+		},
 	},
 	Action: func(c *cli.Context) error {
 		if c.NArg() < 1 {
@@ -87,11 +134,43 @@ var networkCreate = cli.Command{
 			return fmt.Errorf("Failed to get a new client : %s", err.Error())
 		}
 
+		ipv6Mode, err := IPv6Mode.Parse(c.String("ipv6-mode"))
+		if err != nil {
+			return err
+		}
+		mode, err := NetworkMode.Parse(c.String("mode"))
+		if err != nil {
+			return err
+		}
+		if c.String("bridge") != "" && !c.IsSet("mode") {
+			mode = NetworkMode.BRIDGE
+		}
+		vlans, err := parseVLANs(c.String("vlan"))
+		if err != nil {
+			return err
+		}
+		ipVersion := IPVersion.IPv4
+		if strings.Contains(c.String("cidr"), ":") {
+			ipVersion = IPVersion.IPv6
+		}
+		if c.Bool("ha") && ipVersion != IPVersion.IPv4 {
+			return fmt.Errorf("Highly available gateways need an IPv4 network")
+		}
+		if c.Bool("ha") && mode == NetworkMode.BRIDGE {
+			return fmt.Errorf("A network in bridge mode has no gateway, the router of the bridged LAN is used")
+		}
+
 		networkRequest := model.NetworkRequest{
 			Name:       c.Args().First(),
-			IPVersion:  IPVersion.IPv4,
+			IPVersion:  ipVersion,
 			CIDR:       c.String("cidr"),
-			DNSServers: []string{},
+			IPv6CIDR:   c.String("cidr6"),
+			IPv6Mode:   ipv6Mode,
+			DNSServers: c.StringSlice("dns"),
+			DNSDomain:  c.String("domain"),
+			Mode:       mode,
+			Bridge:     c.String("bridge"),
+			VLANs:      vlans,
 		}
 		network, err := client.CreateNetwork(networkRequest)
 		if err != nil {
@@ -99,6 +178,16 @@ var networkCreate = cli.Command{
 
 		}
 
+		if mode == NetworkMode.BRIDGE {
+			// The hosts are plugged on an existing LAN, which has its own addressing and router
+			err = metadata.SaveNetwork(client, network)
+			if err != nil {
+				return fmt.Errorf("Failed to save network metadata into object storage : %s", err.Error())
+			}
+			displayNetwork(network)
+			return nil
+		}
+
 		image, err := client.GetImage(c.String("os"))
 		if err != nil {
 			return fmt.Errorf("Failed to get the image : %s", err.Error())
@@ -119,7 +208,7 @@ var networkCreate = cli.Command{
 
 		gwRequest := model.GatewayRequest{
 			Network:    network,
-			CIDR:       c.String("cidr"),
+			CIDR:       network.CIDR,
 			TemplateID: template.ID,
 			ImageID:    image.ID,
 			KeyPair:    nil,
@@ -140,7 +229,18 @@ var networkCreate = cli.Command{
 
 		network.GatewayID = gw.ID
 
-		metadata.SaveNetwork(client, network)
+		err = metadata.SaveNetwork(client, network)
+		if err != nil {
+			return fmt.Errorf("Failed to save network metadata into object storage : %s", err.Error())
+		}
+
+		if c.Bool("ha") {
+			gwRequest.Name = "gw2-" + network.Name
+			err = createSecondaryGateway(client, network, gw, gwRequest)
+			if err != nil {
+				return err
+			}
+		}
 
 		displayNetwork(network)
 
@@ -173,20 +273,22 @@ var networkDelete = cli.Command{
 				return fmt.Errorf("Network '%s' not found in metadatas", networkName)
 			}
 			network := mNetwork.Get()
-			mGW, err := metadata.LoadHost(client, network.GatewayID)
-			if err != nil || mGW == nil {
-				return fmt.Errorf("Network '%s' not found in metadatas", networkName)
-			}
-			gw := mGW.Get()
-
-			err = client.DeleteHost(gw.ID)
-			if err != nil {
-				return fmt.Errorf("Failed to delete '%s' gateway : %s", gw.Name, err.Error())
-			}
-			fmt.Println(fmt.Sprintf("Gateway '%s' sucessfully deleted", networkName))
-			err = metadata.RemoveHost(client, gw)
-			if err != nil {
-				return fmt.Errorf("Failed to remove gateway '%s' from metadatas : %s", gw.Name, err.Error())
+			for _, gwID := range networkGatewayIDs(network) {
+				mGW, err := metadata.LoadHost(client, gwID)
+				if err != nil || mGW == nil {
+					return fmt.Errorf("Network '%s' not found in metadatas", networkName)
+				}
+				gw := mGW.Get()
+
+				err = client.DeleteHost(gw.ID)
+				if err != nil {
+					return fmt.Errorf("Failed to delete '%s' gateway : %s", gw.Name, err.Error())
+				}
+				fmt.Println(fmt.Sprintf("Gateway '%s' sucessfully deleted", gw.Name))
+				err = metadata.RemoveHost(client, gw)
+				if err != nil {
+					return fmt.Errorf("Failed to remove gateway '%s' from metadatas : %s", gw.Name, err.Error())
+				}
 			}
 
 			err = client.DeleteNetwork(networkName)
@@ -257,6 +359,149 @@ var networkInspect = cli.Command{
 		network := mNetwork.Get()
 
 		displayNetwork(network)
+		if network.GatewayVIP != "" {
+			fmt.Println("	Failover :")
+			for _, gwID := range networkGatewayIDs(network) {
+				fmt.Println("		"+gwID+"	: ", getGatewayVRRPState(gwID, network.GatewayVIP))
+			}
+		}
+
+		return nil
+	},
+}
+
+var networkStart = cli.Command{
+	Name:      "start",
+	Usage:     "start NETWORK",
+	ArgsUsage: "<network_name>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("Missing mandatory argument <Network_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		err = client.StartNetwork(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to start network '%s' : %s", c.Args().First(), err.Error())
+		}
+		fmt.Println(fmt.Sprintf("Network '%s' successfully started", c.Args().First()))
+
+		return nil
+	},
+}
+
+var networkStop = cli.Command{
+	Name:      "stop",
+	Usage:     "stop NETWORK",
+	ArgsUsage: "<network_name>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("Missing mandatory argument <Network_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		err = client.StopNetwork(c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to stop network '%s' : %s", c.Args().First(), err.Error())
+		}
+		fmt.Println(fmt.Sprintf("Network '%s' successfully stopped", c.Args().First()))
+
+		return nil
+	},
+}
+
+var networkAutostart = cli.Command{
+	Name:      "autostart",
+	Usage:     "start NETWORK with the hypervisor",
+	ArgsUsage: "<network_name>",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "disable",
+			Usage: "Disable the autostart of the network",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("Missing mandatory argument <Network_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		autostart := !c.Bool("disable")
+		err = client.SetNetworkAutostart(c.Args().First(), autostart)
+		if err != nil {
+			return fmt.Errorf("Failed to set autostart of network '%s' : %s", c.Args().First(), err.Error())
+		}
+		if autostart {
+			fmt.Println(fmt.Sprintf("Network '%s' will start with the hypervisor", c.Args().First()))
+		} else {
+			fmt.Println(fmt.Sprintf("Network '%s' will no longer start with the hypervisor", c.Args().First()))
+		}
+
+		return nil
+	},
+}
+
+var networkPersist = cli.Command{
+	Name:      "persist",
+	Usage:     "make transient NETWORKs, created by older versions, persistent and autostarted",
+	ArgsUsage: "[<network_name>...]",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "all",
+			Usage: "Persist every network known in metadatas",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 && !c.Bool("all") {
+			return fmt.Errorf("Missing mandatory argument <Network_name> or --all flag")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		var networkList []string
+		if c.Bool("all") {
+			err = metadata.NewNetwork(client).Browse(func(network *model.Network) error {
+				networkList = append(networkList, network.Name)
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("Failed to list networks : %s", err.Error())
+			}
+		} else {
+			networkList = append(networkList, c.Args().First())
+			networkList = append(networkList, c.Args().Tail()...)
+		}
+
+		for _, networkName := range networkList {
+			persistent, err := client.IsNetworkPersistent(networkName)
+			if err != nil {
+				return fmt.Errorf("Failed to get network '%s' : %s", networkName, err.Error())
+			}
+			if persistent {
+				fmt.Println(fmt.Sprintf("Network '%s' is already persistent", networkName))
+				continue
+			}
+			err = client.SetNetworkAutostart(networkName, true)
+			if err != nil {
+				return fmt.Errorf("Failed to persist network '%s' : %s", networkName, err.Error())
+			}
+			fmt.Println(fmt.Sprintf("Network '%s' successfully made persistent", networkName))
+		}
 
 		return nil
 	},
@@ -266,5 +511,45 @@ func displayNetwork(network *model.Network) {
 	fmt.Println("\nHost : ", network.Name)
 	fmt.Println("	ID	: ", network.ID)
 	fmt.Println("	CIDR 	: ", network.CIDR)
+	fmt.Println("	Mode 	: ", network.Mode)
+	if network.Bridge != "" {
+		fmt.Println("	Bridge	: ", network.Bridge)
+	}
+	if network.VirtualPort != "" {
+		fmt.Println("	Port	: ", network.VirtualPort)
+	}
+	if len(network.VLANs) > 0 {
+		fmt.Println("	VLANs	: ", network.VLANs)
+	}
+	if network.IPv6CIDR != "" {
+		fmt.Println("	IPv6 CIDR: ", network.IPv6CIDR)
+		fmt.Println("	IPv6 mode: ", network.IPv6Mode)
+	}
+	if network.DNSDomain != "" {
+		fmt.Println("	Domain	: ", network.DNSDomain)
+	}
+	if len(network.DNSServers) > 0 {
+		fmt.Println("	DNS	: ", strings.Join(network.DNSServers, ", "))
+	}
 	fmt.Println("	GatewayID: ", network.GatewayID)
+	if network.SecondaryGatewayID != "" {
+		fmt.Println("	Secondary GatewayID: ", network.SecondaryGatewayID)
+		fmt.Println("	Gateway VIP: ", network.GatewayVIP)
+	}
+}
+
+// parseVLANs parses a comma separated list of VLAN tags
+func parseVLANs(str string) ([]int, error) {
+	vlans := []int{}
+	if str == "" {
+		return vlans, nil
+	}
+	for _, tag := range strings.Split(str, ",") {
+		vlan, err := strconv.Atoi(strings.TrimSpace(tag))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid VLAN tag '%s'", tag)
+		}
+		vlans = append(vlans, vlan)
+	}
+	return vlans, nil
 }
@@ -0,0 +1,450 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/CS-SI/LocalDriver/api"
+	"github.com/CS-SI/LocalDriver/metadata"
+	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	"github.com/CS-SI/LocalDriver/model/enums/PortForwardMode"
+	"github.com/CS-SI/LocalDriver/model/enums/RuleProtocol"
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+
+	"github.com/urfave/cli"
+)
+
+// gatewaySaveRulesScript makes the iptables rules of a gateway survive its reboots, the package restoring the rules at
+// boot is installed if needed
+const gatewaySaveRulesScript = `
+if which apt-get >/dev/null 2>&1; then
+	if ! dpkg -s iptables-persistent >/dev/null 2>&1; then
+		export DEBIAN_FRONTEND=noninteractive
+		echo "iptables-persistent iptables-persistent/autosave_v4 boolean false" | debconf-set-selections
+		echo "iptables-persistent iptables-persistent/autosave_v6 boolean false" | debconf-set-selections
+		apt-get install -y -q iptables-persistent
+	fi
+	mkdir -p /etc/iptables
+	iptables-save >/etc/iptables/rules.v4
+elif which yum >/dev/null 2>&1; then
+	rpm -q iptables-services >/dev/null 2>&1 || yum install -y -q iptables-services
+	systemctl enable iptables
+	iptables-save >/etc/sysconfig/iptables
+else
+	echo "No way to persist the iptables rules on this system" >&2
+	exit 1
+fi
+`
+
+//PortForwardCmd port forward command
+var PortForwardCmd = cli.Command{
+	Name:  "portforward",
+	Usage: "portforward COMMAND",
+	Subcommands: []cli.Command{
+		portForwardAdd,
+		portForwardDelete,
+		portForwardList,
+		portForwardRestore,
+	},
+}
+
+var portForwardAdd = cli.Command{
+	Name:      "add",
+	Aliases:   []string{"new", "create"},
+	Usage:     "Forward a public port of the gateway or of the hypervisor to a port of a host",
+	ArgsUsage: "<Host_name|Host_ID>:<Port>",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "public",
+			Usage: "Public port forwarded to the host (mandatory)",
+		},
+		cli.StringFlag{
+			Name:  "protocol",
+			Value: "tcp",
+			Usage: "Protocol of the forwarded port (tcp, udp)",
+		},
+		cli.StringFlag{
+			Name:  "via",
+			Value: "gateway",
+			Usage: "Where the public port is opened, on the gateway of the network of the host or on the hypervisor (gateway, hypervisor)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>:<Port>")
+		}
+		if c.Int("public") < 1 || c.Int("public") > 65535 {
+			return fmt.Errorf("Missing or invalid --public port")
+		}
+		mode, err := PortForwardMode.Parse(c.String("via"))
+		if err != nil {
+			return err
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		host, mNetwork, forward, err := loadPortForwardTarget(client, c.Args().First(), c.String("protocol"))
+		if err != nil {
+			return err
+		}
+		network := mNetwork.Get()
+		forward.Mode = mode
+		forward.PublicPort = c.Int("public")
+		forward.ID = portForwardID(forward.Mode, forward.Protocol, forward.PublicPort)
+		if forward.Mode == PortForwardMode.GATEWAY {
+			if network.GatewayID == "" {
+				return fmt.Errorf("Network '%s' has no gateway, forward a port of the hypervisor instead", network.Name)
+			}
+			forward.GatewayID = network.GatewayID
+		}
+
+		forwards, err := mNetwork.ListPortForwards()
+		if err != nil {
+			return fmt.Errorf("Failed to list the port forwards of network '%s' : %s", network.Name, err.Error())
+		}
+		for _, other := range forwards {
+			if other.ID == forward.ID {
+				return fmt.Errorf("Port %d/%s is already forwarded to host '%s'", forward.PublicPort, strings.ToLower(forward.Protocol.String()), other.HostName)
+			}
+		}
+
+		err = applyPortForward(client, network, forward)
+		if err != nil {
+			return fmt.Errorf("Failed to forward port %d to host '%s' : %s", forward.PublicPort, host.Name, err.Error())
+		}
+
+		err = mNetwork.AddPortForward(forward)
+		if err != nil {
+			return fmt.Errorf("Failed to add the port forward to network '%s' metadatas : %s", network.Name, err.Error())
+		}
+		err = mNetwork.Write()
+		if err != nil {
+			return fmt.Errorf("Failed to save network metadatas : %s", err.Error())
+		}
+
+		displayPortForward(forward)
+
+		return nil
+	},
+}
+
+var portForwardDelete = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"rm", "remove"},
+	Usage:     "Delete the forwards to a port of a host",
+	ArgsUsage: "<Host_name|Host_ID>:<Port>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "protocol",
+			Value: "tcp",
+			Usage: "Protocol of the forwarded port (tcp, udp)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Host_name>:<Port>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		host, mNetwork, target, err := loadPortForwardTarget(client, c.Args().First(), c.String("protocol"))
+		if err != nil {
+			return err
+		}
+		forwards, err := mNetwork.ListPortForwards()
+		if err != nil {
+			return fmt.Errorf("Failed to list the port forwards of network '%s' : %s", mNetwork.Get().Name, err.Error())
+		}
+
+		found := false
+		for _, forward := range forwards {
+			if forward.HostID != target.HostID || forward.PrivatePort != target.PrivatePort || forward.Protocol != target.Protocol {
+				continue
+			}
+			err = deletePortForward(client, mNetwork, forward)
+			if err != nil {
+				return err
+			}
+			found = true
+			fmt.Println(fmt.Sprintf("Port %d/%s sucessfully unforwarded", forward.PublicPort, strings.ToLower(forward.Protocol.String())))
+		}
+		if !found {
+			return fmt.Errorf("Port %d/%s of host '%s' is not forwarded", target.PrivatePort, strings.ToLower(target.Protocol.String()), host.Name)
+		}
+
+		return nil
+	},
+}
+
+var portForwardList = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List the port forwards to the hosts",
+	Action: func(c *cli.Context) error {
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		err = metadata.NewNetwork(client).Browse(func(network *model.Network) error {
+			forwards, err := metadata.NewNetwork(client).Carry(network).ListPortForwards()
+			if err != nil {
+				return err
+			}
+			for _, forward := range forwards {
+				displayPortForward(forward)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to list port forwards : %s", err.Error())
+		}
+
+		return nil
+	},
+}
+
+var portForwardRestore = cli.Command{
+	Name:  "restore",
+	Usage: "Apply again the port forwards of the hypervisor recorded in metadatas, if its saved rules were lost",
+	Action: func(c *cli.Context) error {
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		err = metadata.NewNetwork(client).Browse(func(network *model.Network) error {
+			forwards, err := metadata.NewNetwork(client).Carry(network).ListPortForwards()
+			if err != nil {
+				return err
+			}
+			for _, forward := range forwards {
+				if forward.Mode != PortForwardMode.HYPERVISOR {
+					continue
+				}
+				err = client.RemoveHypervisorPortForward(forward)
+				if err != nil {
+					return err
+				}
+				err = client.ForwardHypervisorPort(network.ID, forward)
+				if err != nil {
+					return err
+				}
+				fmt.Println(fmt.Sprintf("Port %d/%s forwarded to %s:%d", forward.PublicPort, strings.ToLower(forward.Protocol.String()), forward.HostName, forward.PrivatePort))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to restore port forwards : %s", err.Error())
+		}
+
+		return nil
+	},
+}
+
+// portForwardID builds the ID of a port forward, unique for a public port
+func portForwardID(mode PortForwardMode.Enum, protocol RuleProtocol.Enum, publicPort int) string {
+	return fmt.Sprintf("%s-%s-%d", strings.ToLower(mode.String()), strings.ToLower(protocol.String()), publicPort)
+}
+
+// loadPortForwardTarget parses <Host_name|Host_ID>:<Port> and returns the host, its default network and
+// a port forward to it, with the public side left to fill
+func loadPortForwardTarget(client api.ClientAPI, target string, protocolName string) (*model.Host, *metadata.Network, *propsv1.NetworkPortForward, error) {
+	index := strings.LastIndex(target, ":")
+	if index < 0 {
+		return nil, nil, nil, fmt.Errorf("Invalid target '%s', expected <Host_name>:<Port>", target)
+	}
+	hostName := target[:index]
+	port, err := strconv.Atoi(target[index+1:])
+	if err != nil || port < 1 || port > 65535 {
+		return nil, nil, nil, fmt.Errorf("Invalid port '%s'", target[index+1:])
+	}
+	protocol, err := RuleProtocol.Parse(protocolName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if protocol != RuleProtocol.TCP && protocol != RuleProtocol.UDP {
+		return nil, nil, nil, fmt.Errorf("Only tcp and udp ports can be forwarded")
+	}
+
+	mHost, err := metadata.LoadHost(client, hostName)
+	if err != nil || mHost == nil {
+		return nil, nil, nil, fmt.Errorf("Host '%s' not found in metadatas", hostName)
+	}
+	host := mHost.Get()
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	err = host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hostIP := hostNetworkV1.IPv4Addresses[hostNetworkV1.DefaultNetworkID]
+	if hostIP == "" {
+		return nil, nil, nil, fmt.Errorf("Host '%s' has no IPv4 address on its default network", host.Name)
+	}
+	mNetwork, err := metadata.LoadNetwork(client, hostNetworkV1.DefaultNetworkID)
+	if err != nil || mNetwork == nil {
+		return nil, nil, nil, fmt.Errorf("Network '%s' not found in metadatas", hostNetworkV1.DefaultNetworkID)
+	}
+
+	return host, mNetwork, &propsv1.NetworkPortForward{
+		Protocol:    protocol,
+		HostID:      host.ID,
+		HostName:    host.Name,
+		HostIP:      hostIP,
+		PrivatePort: port,
+	}, nil
+}
+
+// gatewayPortForwardRules returns the iptables rule specifications of a port forward on the gateways of network
+func gatewayPortForwardRules(network *model.Network, forward *propsv1.NetworkPortForward) map[string]string {
+	protocol := strings.ToLower(forward.Protocol.String())
+	comment := "virt-portforward-" + forward.ID
+	rules := map[string]string{
+		"nat PREROUTING": fmt.Sprintf("-p %s --dport %d -m addrtype --dst-type LOCAL -m comment --comment %s -j DNAT --to-destination %s:%d",
+			protocol, forward.PublicPort, comment, forward.HostIP, forward.PrivatePort),
+		"filter FORWARD": fmt.Sprintf("-p %s -d %s --dport %d -m conntrack --ctstate NEW -m comment --comment %s -j ACCEPT",
+			protocol, forward.HostIP, forward.PrivatePort, comment),
+	}
+	if network.SecondaryGatewayID != "" {
+		// The host answers through the gateway holding the virtual IP, the connections forwarded by the other one
+		// have to come from its private address to get the replies back
+		rules["nat POSTROUTING"] = fmt.Sprintf("-p %s -d %s --dport %d -m conntrack --ctstate DNAT -m comment --comment %s -j MASQUERADE",
+			protocol, forward.HostIP, forward.PrivatePort, comment)
+	}
+	return rules
+}
+
+// applyPortForward creates the DNAT rules of a port forward on the gateway or on the hypervisor
+func applyPortForward(client api.ClientAPI, network *model.Network, forward *propsv1.NetworkPortForward) error {
+	if forward.Mode == PortForwardMode.HYPERVISOR {
+		return client.ForwardHypervisorPort(network.ID, forward)
+	}
+
+	script := "set -e\n"
+	for chain, rule := range gatewayPortForwardRules(network, forward) {
+		fields := strings.Fields(chain)
+		script += fmt.Sprintf("iptables -t %s -C %s %s 2>/dev/null || iptables -t %s -I %s %s\n", fields[0], fields[1], rule, fields[0], fields[1], rule)
+	}
+	// Highly available gateways both forward the port, to keep it open after a failover
+	for _, gwID := range networkGatewayIDs(network) {
+		sshConfig, err := GetSSHConfigFromHostName(gwID)
+		if err != nil {
+			return fmt.Errorf("Failed get the sshConfig : %s", err.Error())
+		}
+		err = runNetworkScript(sshConfig, script+gatewaySaveRulesScript)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePortForward removes the DNAT rules of a port forward and forgets it in the metadatas of its network
+func deletePortForward(client api.ClientAPI, mNetwork *metadata.Network, forward *propsv1.NetworkPortForward) error {
+	if forward.Mode == PortForwardMode.HYPERVISOR {
+		err := client.RemoveHypervisorPortForward(forward)
+		if err != nil {
+			return fmt.Errorf("Failed to remove port forward '%s' : %s", forward.ID, err.Error())
+		}
+	} else {
+		script := "set -e\n"
+		for chain, rule := range gatewayPortForwardRules(mNetwork.Get(), forward) {
+			fields := strings.Fields(chain)
+			script += fmt.Sprintf("while iptables -t %s -C %s %s 2>/dev/null; do iptables -t %s -D %s %s; done\n", fields[0], fields[1], rule, fields[0], fields[1], rule)
+		}
+		for _, gwID := range networkGatewayIDs(mNetwork.Get()) {
+			sshConfig, err := GetSSHConfigFromHostName(gwID)
+			if err != nil {
+				return fmt.Errorf("Failed get the sshConfig : %s", err.Error())
+			}
+			err = runNetworkScript(sshConfig, script+gatewaySaveRulesScript)
+			if err != nil {
+				return fmt.Errorf("Failed to remove port forward '%s' from the gateway : %s", forward.ID, err.Error())
+			}
+		}
+	}
+
+	err := mNetwork.RemovePortForward(forward.ID)
+	if err != nil {
+		return fmt.Errorf("Failed to remove port forward '%s' from network metadatas : %s", forward.ID, err.Error())
+	}
+	err = mNetwork.Write()
+	if err != nil {
+		return fmt.Errorf("Failed to save network metadatas : %s", err.Error())
+	}
+	return nil
+}
+
+// removeHostPortForwards deletes the port forwards to a host, before its deletion
+func removeHostPortForwards(client api.ClientAPI, host *model.Host) error {
+	hostNetworkV1 := propsv1.NewHostNetwork()
+	err := host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+	if err != nil {
+		return err
+	}
+	for networkID := range hostNetworkV1.NetworksByID {
+		mNetwork, err := metadata.LoadNetwork(client, networkID)
+		if err != nil || mNetwork == nil {
+			continue
+		}
+		forwards, err := mNetwork.ListPortForwards()
+		if err != nil {
+			return fmt.Errorf("Failed to list the port forwards of network '%s' : %s", mNetwork.Get().Name, err.Error())
+		}
+		for _, forward := range forwards {
+			if forward.HostID != host.ID {
+				continue
+			}
+			err = deletePortForward(client, mNetwork, forward)
+			if err == nil {
+				continue
+			}
+			// The rules left on an unreachable gateway forward to nothing once the host is deleted
+			fmt.Printf("Failed to remove port forward '%s', its rules have to be removed manually : %s\n", forward.ID, err.Error())
+			err = mNetwork.RemovePortForward(forward.ID)
+			if err != nil {
+				return fmt.Errorf("Failed to remove port forward '%s' from network metadatas : %s", forward.ID, err.Error())
+			}
+			err = mNetwork.Write()
+			if err != nil {
+				return fmt.Errorf("Failed to save network metadatas : %s", err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func displayPortForward(forward *propsv1.NetworkPortForward) {
+	fmt.Println("\nPort forward : ", forward.ID)
+	fmt.Println("	Via	: ", forward.Mode)
+	fmt.Println("	Public	: ", fmt.Sprintf("%d/%s", forward.PublicPort, strings.ToLower(forward.Protocol.String())))
+	fmt.Println("	Host	: ", forward.HostName)
+	fmt.Println("	Target	: ", fmt.Sprintf("%s:%d", forward.HostIP, forward.PrivatePort))
+	if forward.GatewayID != "" {
+		fmt.Println("	Gateway	: ", forward.GatewayID)
+	}
+}
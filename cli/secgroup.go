@@ -0,0 +1,504 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/CS-SI/LocalDriver/api"
+	"github.com/CS-SI/LocalDriver/metadata"
+	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	"github.com/CS-SI/LocalDriver/model/enums/RuleDirection"
+	"github.com/CS-SI/LocalDriver/model/enums/RuleProtocol"
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+
+	"github.com/urfave/cli"
+)
+
+//SecGroupCmd security group command
+var SecGroupCmd = cli.Command{
+	Name:  "secgroup",
+	Usage: "secgroup COMMAND",
+	Subcommands: []cli.Command{
+		secGroupCreate,
+		secGroupDelete,
+		secGroupList,
+		secGroupInspect,
+		secGroupRule,
+		secGroupAttach,
+		secGroupDetach,
+	},
+}
+
+var secGroupCreate = cli.Command{
+	Name:      "create",
+	Aliases:   []string{"new"},
+	Usage:     "Create a security group, dropping all the ingress traffic until rules are added",
+	ArgsUsage: "<Group_name>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "description",
+			Value: "",
+			Usage: "Description of the security group",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Group_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		group, err := client.CreateSecurityGroup(model.SecurityGroupRequest{
+			Name:        c.Args().First(),
+			Description: c.String("description"),
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to create security group : %s", err.Error())
+		}
+
+		err = metadata.SaveSecurityGroup(client, group)
+		if err != nil {
+			return fmt.Errorf("Failed to save security group metadatas : %s", err.Error())
+		}
+
+		displaySecurityGroup(group)
+
+		return nil
+	},
+}
+
+var secGroupDelete = cli.Command{
+	Name:      "delete",
+	Aliases:   []string{"rm", "remove"},
+	Usage:     "Delete security groups",
+	ArgsUsage: "<Group_name|Group_ID> [<Group_name|Group_ID>...]",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return fmt.Errorf("Missing mandatory argument <Group_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		var groupList []string
+		groupList = append(groupList, c.Args().First())
+		groupList = append(groupList, c.Args().Tail()...)
+
+		for _, groupName := range groupList {
+			mGroup, err := metadata.LoadSecurityGroup(client, groupName)
+			if err != nil {
+				return fmt.Errorf("Failed to load security group '%s' from metadatas : %s", groupName, err.Error())
+			}
+			group := mGroup.Get()
+
+			if len(group.Hosts) != 0 {
+				return fmt.Errorf("Security group '%s' is applied to hosts, detach it first", groupName)
+			}
+			referencingGroups, err := listReferencingSecurityGroups(client, group.ID)
+			if err != nil {
+				return err
+			}
+			if len(referencingGroups) != 0 {
+				names := []string{}
+				for _, referencingGroup := range referencingGroups {
+					names = append(names, referencingGroup.Name)
+				}
+				return fmt.Errorf("Security group '%s' is used as source group by %s, delete them first", groupName, strings.Join(names, ", "))
+			}
+
+			err = client.DeleteSecurityGroup(group.ID)
+			if err != nil {
+				return fmt.Errorf("Failed to delete '%s' security group : %s", groupName, err.Error())
+			}
+			fmt.Println(fmt.Sprintf("Security group '%s' sucessfully deleted", groupName))
+
+			err = metadata.RemoveSecurityGroup(client, group.ID)
+			if err != nil {
+				return fmt.Errorf("Failed to remove security group '%s' from metadatas : %s", groupName, err.Error())
+			}
+		}
+
+		return nil
+	},
+}
+
+var secGroupList = cli.Command{
+	Name:    "list",
+	Aliases: []string{"ls"},
+	Usage:   "List security groups",
+	Action: func(c *cli.Context) error {
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		err = metadata.NewSecurityGroup(client).Browse(func(group *model.SecurityGroup) error {
+			displaySecurityGroup(group)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to list security groups : %s", err.Error())
+		}
+		return nil
+	},
+}
+
+var secGroupInspect = cli.Command{
+	Name:      "inspect",
+	Aliases:   []string{"show"},
+	Usage:     "Inspect security group",
+	ArgsUsage: "<Group_name|Group_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Group_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mGroup, err := metadata.LoadSecurityGroup(client, c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to load security group '%s' from metadatas : %s", c.Args().First(), err.Error())
+		}
+
+		displaySecurityGroup(mGroup.Get())
+
+		return nil
+	},
+}
+
+var secGroupRule = cli.Command{
+	Name:  "rule",
+	Usage: "rule COMMAND",
+	Subcommands: []cli.Command{
+		secGroupRuleAdd,
+	},
+}
+
+var secGroupRuleAdd = cli.Command{
+	Name:      "add",
+	Usage:     "Allow some traffic of the hosts a security group is applied to",
+	ArgsUsage: "<Group_name|Group_ID>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "direction",
+			Value: "ingress",
+			Usage: "Direction of the allowed traffic (ingress or egress)",
+		},
+		cli.StringFlag{
+			Name:  "protocol",
+			Value: "tcp",
+			Usage: "Protocol of the allowed traffic (all, tcp, udp or icmp)",
+		},
+		cli.StringFlag{
+			Name:  "port",
+			Value: "",
+			Usage: "Port or port range (like 8000-8080) of the allowed traffic, any port if empty (tcp and udp only)",
+		},
+		cli.StringFlag{
+			Name:  "cidr",
+			Value: "",
+			Usage: "Source (ingress) or destination (egress) of the allowed traffic, any address if empty",
+		},
+		cli.StringFlag{
+			Name:  "source-group",
+			Value: "",
+			Usage: "Allow the traffic from (ingress) or to (egress) the hosts of this security group instead of --cidr",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("Missing mandatory argument <Group_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mGroup, err := metadata.LoadSecurityGroup(client, c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Failed to load security group '%s' from metadatas : %s", c.Args().First(), err.Error())
+		}
+		group := mGroup.Get()
+
+		rule := &model.SecurityGroupRule{}
+		rule.Direction, err = RuleDirection.Parse(c.String("direction"))
+		if err != nil {
+			return err
+		}
+		rule.Protocol, err = RuleProtocol.Parse(c.String("protocol"))
+		if err != nil {
+			return err
+		}
+		if c.String("port") != "" {
+			if rule.Protocol != RuleProtocol.TCP && rule.Protocol != RuleProtocol.UDP {
+				return fmt.Errorf("A port can only be set for tcp and udp rules")
+			}
+			rule.PortFrom, rule.PortTo, err = parsePortRange(c.String("port"))
+			if err != nil {
+				return err
+			}
+		}
+		if c.String("cidr") != "" && c.String("source-group") != "" {
+			return fmt.Errorf("--cidr and --source-group are mutually exclusive")
+		}
+		if c.String("cidr") != "" {
+			_, _, err = net.ParseCIDR(c.String("cidr"))
+			if err != nil {
+				return fmt.Errorf("Invalid CIDR '%s' : %s", c.String("cidr"), err.Error())
+			}
+			rule.CIDR = c.String("cidr")
+		}
+		if c.String("source-group") != "" {
+			mSourceGroup, err := metadata.LoadSecurityGroup(client, c.String("source-group"))
+			if err != nil {
+				return fmt.Errorf("Failed to load security group '%s' from metadatas : %s", c.String("source-group"), err.Error())
+			}
+			rule.SourceGroupID = mSourceGroup.Get().ID
+		}
+
+		group.Rules = append(group.Rules, rule)
+		err = client.UpdateSecurityGroup(group)
+		if err != nil {
+			return fmt.Errorf("Failed to add the rule to security group '%s' : %s", group.Name, err.Error())
+		}
+
+		err = metadata.SaveSecurityGroup(client, group)
+		if err != nil {
+			return fmt.Errorf("Failed to save security group metadatas : %s", err.Error())
+		}
+
+		displaySecurityGroup(group)
+
+		return nil
+	},
+}
+
+var secGroupAttach = cli.Command{
+	Name:      "attach",
+	Usage:     "Apply a security group to a host",
+	ArgsUsage: "<Group_name|Group_ID> <Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			return fmt.Errorf("Missing mandatory arguments <Group_name> <Host_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mGroup, err := metadata.LoadSecurityGroup(client, c.Args().Get(0))
+		if err != nil {
+			return fmt.Errorf("Failed to load security group '%s' from metadatas : %s", c.Args().Get(0), err.Error())
+		}
+		group := mGroup.Get()
+		mHost, err := metadata.LoadHost(client, c.Args().Get(1))
+		if err != nil || mHost == nil {
+			return fmt.Errorf("Host '%s' not found in metadatas", c.Args().Get(1))
+		}
+		host := mHost.Get()
+
+		err = client.AttachSecurityGroup(host.ID, group.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to attach security group '%s' to host '%s' : %s", group.Name, host.Name, err.Error())
+		}
+		fmt.Println(fmt.Sprintf("Security group '%s' successfully attached to host '%s'", group.Name, host.Name))
+
+		mGroup.AttachHost(host)
+		err = mGroup.Write()
+		if err != nil {
+			return fmt.Errorf("Failed to save security group metadatas : %s", err.Error())
+		}
+		hostSecurityGroupsV1 := propsv1.NewHostSecurityGroups()
+		host.Properties.Get(HostProperty.SecurityGroupsV1, hostSecurityGroupsV1)
+		hostSecurityGroupsV1.ByID[group.ID] = group.Name
+		hostSecurityGroupsV1.ByName[group.Name] = group.ID
+		host.Properties.Set(HostProperty.SecurityGroupsV1, hostSecurityGroupsV1)
+		err = metadata.SaveHost(client, host)
+		if err != nil {
+			return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+		}
+
+		return updateReferencingSecurityGroups(client, group.ID)
+	},
+}
+
+var secGroupDetach = cli.Command{
+	Name:      "detach",
+	Usage:     "Stop applying a security group to a host",
+	ArgsUsage: "<Group_name|Group_ID> <Host_name|Host_ID>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			return fmt.Errorf("Missing mandatory arguments <Group_name> <Host_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mGroup, err := metadata.LoadSecurityGroup(client, c.Args().Get(0))
+		if err != nil {
+			return fmt.Errorf("Failed to load security group '%s' from metadatas : %s", c.Args().Get(0), err.Error())
+		}
+		group := mGroup.Get()
+		mHost, err := metadata.LoadHost(client, c.Args().Get(1))
+		if err != nil || mHost == nil {
+			return fmt.Errorf("Host '%s' not found in metadatas", c.Args().Get(1))
+		}
+		host := mHost.Get()
+
+		err = client.DetachSecurityGroup(host.ID, group.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to detach security group '%s' from host '%s' : %s", group.Name, host.Name, err.Error())
+		}
+		fmt.Println(fmt.Sprintf("Security group '%s' successfully detached from host '%s'", group.Name, host.Name))
+
+		hostSecurityGroupsV1 := propsv1.NewHostSecurityGroups()
+		host.Properties.Get(HostProperty.SecurityGroupsV1, hostSecurityGroupsV1)
+		delete(hostSecurityGroupsV1.ByID, group.ID)
+		delete(hostSecurityGroupsV1.ByName, group.Name)
+		host.Properties.Set(HostProperty.SecurityGroupsV1, hostSecurityGroupsV1)
+		err = metadata.SaveHost(client, host)
+		if err != nil {
+			return fmt.Errorf("Failed to save host metadata into object storage : %s", err.Error())
+		}
+
+		return detachSecurityGroupHost(client, mGroup, host.ID)
+	},
+}
+
+// parsePortRange parses a port ("22") or a port range ("8000-8080")
+func parsePortRange(portRange string) (int, int, error) {
+	bounds := strings.SplitN(portRange, "-", 2)
+	portFrom, err := strconv.Atoi(bounds[0])
+	if err != nil || portFrom < 1 || portFrom > 65535 {
+		return 0, 0, fmt.Errorf("Invalid port '%s'", bounds[0])
+	}
+	portTo := portFrom
+	if len(bounds) == 2 {
+		portTo, err = strconv.Atoi(bounds[1])
+		if err != nil || portTo < portFrom || portTo > 65535 {
+			return 0, 0, fmt.Errorf("Invalid port range '%s'", portRange)
+		}
+	}
+	return portFrom, portTo, nil
+}
+
+// listReferencingSecurityGroups returns the security groups having a rule whose source group is the group identified by groupID
+func listReferencingSecurityGroups(client api.ClientAPI, groupID string) ([]*model.SecurityGroup, error) {
+	groups := []*model.SecurityGroup{}
+	err := metadata.NewSecurityGroup(client).Browse(func(group *model.SecurityGroup) error {
+		for _, rule := range group.Rules {
+			if rule.SourceGroupID == groupID {
+				groups = append(groups, group)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to browse security groups metadatas : %s", err.Error())
+	}
+	return groups, nil
+}
+
+// updateReferencingSecurityGroups renders again the security groups using the group identified by groupID as source group,
+// to follow the addresses of its hosts
+func updateReferencingSecurityGroups(client api.ClientAPI, groupID string) error {
+	groups, err := listReferencingSecurityGroups(client, groupID)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		err = client.UpdateSecurityGroup(group)
+		if err != nil {
+			return fmt.Errorf("Failed to update security group '%s' : %s", group.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// detachSecurityGroupHost unlinks the host identified by hostID from the security group in metadata
+func detachSecurityGroupHost(client api.ClientAPI, mGroup *metadata.SecurityGroup, hostID string) error {
+	mGroup.DetachHost(hostID)
+	err := mGroup.Write()
+	if err != nil {
+		return fmt.Errorf("Failed to save security group metadatas : %s", err.Error())
+	}
+	return updateReferencingSecurityGroups(client, mGroup.Get().ID)
+}
+
+// removeHostFromSecurityGroups unlinks the deleted host from the security groups it was applied to
+func removeHostFromSecurityGroups(client api.ClientAPI, host *model.Host) error {
+	hostSecurityGroupsV1 := propsv1.NewHostSecurityGroups()
+	host.Properties.Get(HostProperty.SecurityGroupsV1, hostSecurityGroupsV1)
+	for groupID := range hostSecurityGroupsV1.ByID {
+		mGroup, err := metadata.LoadSecurityGroup(client, groupID)
+		if err != nil {
+			continue
+		}
+		err = detachSecurityGroupHost(client, mGroup, host.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func displaySecurityGroup(group *model.SecurityGroup) {
+	fmt.Println("\nSecurity group : ", group.Name)
+	fmt.Println("	ID	: ", group.ID)
+	if group.Description != "" {
+		fmt.Println("	Description : ", group.Description)
+	}
+	fmt.Println("	Rules :")
+	for _, rule := range group.Rules {
+		ports := "any port"
+		if rule.PortFrom > 0 {
+			ports = fmt.Sprintf("port %d", rule.PortFrom)
+			if rule.PortTo > rule.PortFrom {
+				ports = fmt.Sprintf("ports %d-%d", rule.PortFrom, rule.PortTo)
+			}
+		}
+		peer := "any address"
+		if rule.CIDR != "" {
+			peer = rule.CIDR
+		} else if rule.SourceGroupID != "" {
+			peer = "group " + rule.SourceGroupID
+		}
+		fmt.Println("		", rule.Direction, rule.Protocol, ports, peer)
+	}
+	fmt.Println("	Hosts :")
+	for _, hostName := range group.Hosts {
+		fmt.Println("		", hostName)
+	}
+}
@@ -32,6 +32,8 @@ var tenantLocal = map[string]interface{}{
 	"minioAccessKeyID":     "accesKey",
 	"minioSecretAccessKey": "secretKey",
 	"minioUseSSL":          false,
+	"supernetPool":         "10.100.0.0/16",
+	"addressSources":       []string{"agent", "lease", "arp"},
 }
 
 const (
@@ -20,11 +20,14 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/CS-SI/LocalDriver/api"
 	"github.com/CS-SI/LocalDriver/metadata"
 	"github.com/CS-SI/LocalDriver/model"
 	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	"github.com/CS-SI/LocalDriver/model/enums/HostState"
 	"github.com/CS-SI/LocalDriver/model/enums/VolumeProperty"
 	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+	propsv2 "github.com/CS-SI/LocalDriver/model/properties/v2"
 	"github.com/CS-SI/LocalDriver/system"
 	"github.com/CS-SI/LocalDriver/system/nfs"
 
@@ -42,6 +45,9 @@ var VolumeCmd = cli.Command{
 		volumeInspect,
 		volumeAttach,
 		volumeDetach,
+		volumeSnapshot,
+		volumeClone,
+		volumeResize,
 	},
 }
 
@@ -114,6 +120,14 @@ var volumeDelete = cli.Command{
 			}
 			volume := mVolume.Get()
 
+			clones, err := listClones(client, volume)
+			if err != nil {
+				return fmt.Errorf("Failed to list clones of volume '%s' : %s", volumeName, err.Error())
+			}
+			if len(clones) != 0 {
+				return fmt.Errorf("Volume '%s' is used as backing store by volumes %s, delete them first", volumeName, strings.Join(clones, ", "))
+			}
+
 			err = client.DeleteVolume(volumeName)
 			if err != nil {
 				return fmt.Errorf("Failed to delete '%s' volume : %s", volumeName, err.Error())
@@ -430,9 +444,217 @@ var volumeDetach = cli.Command{
 	},
 }
 
+var volumeResize = cli.Command{
+	Name:      "resize",
+	Usage:     "Grow a volume, and its filesystem if the volume is attached to a started host",
+	ArgsUsage: "<Volume_name|Volume_ID>",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "size",
+			Value: 0,
+			Usage: "New size of the volume (in Go)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			_ = cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Missing mandatory argument <Volume_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mVolume, err := metadata.LoadVolume(client, c.Args().First())
+		if err != nil {
+			return fmt.Errorf("Volume '%s' not found in metadatas", c.Args().First())
+		}
+		volume := mVolume.Get()
+		if c.Int("size") <= volume.Size {
+			return fmt.Errorf("The new size must be greater than the current size of the volume (%d Go)", volume.Size)
+		}
+		volumeAttachedV1 := propsv1.NewVolumeAttachments()
+		err = volume.Properties.Get(VolumeProperty.AttachedV1, volumeAttachedV1)
+		if err != nil {
+			return fmt.Errorf("Failed to get volume propertie AttachedV1 : %s", err.Error())
+		}
+
+		resized, err := client.ResizeVolume(volume.ID, c.Int("size"))
+		if err != nil {
+			return fmt.Errorf("Failed to resize volume '%s' : %s", volume.Name, err.Error())
+		}
+
+		// The volume is grown whatever happens inside the hosts
+		volume.Size = resized.Size
+		err = metadata.SaveVolume(client, volume)
+		if err != nil {
+			return fmt.Errorf("Failed to save volume metadatas : %s", err.Error())
+		}
+
+		var failedHosts []string
+		for hostID, hostName := range volumeAttachedV1.Hosts {
+			err = growVolumeFilesystem(client, volume, hostID, hostName)
+			if err != nil {
+				fmt.Printf("Failed to grow the filesystem of the volume on host '%s' : %s\n", hostName, err.Error())
+				failedHosts = append(failedHosts, hostName)
+			}
+		}
+
+		displayVolume(volume)
+
+		if len(failedHosts) > 0 {
+			return fmt.Errorf("Volume '%s' resized, but its filesystem has to be grown manually on hosts %s", volume.Name, strings.Join(failedHosts, ", "))
+		}
+		return nil
+	},
+}
+
+// growVolumeFilesystem grows the filesystem of a resized volume attached to the host identified by hostID
+func growVolumeFilesystem(client api.ClientAPI, volume *model.Volume, hostID string, hostName string) error {
+	hostState, _, _, err := client.GetHostState(hostID)
+	if err != nil {
+		return fmt.Errorf("Failed to get host '%s' state : %s", hostName, err.Error())
+	}
+	if hostState != HostState.STARTED {
+		fmt.Printf("Host '%s' is not started, the filesystem of the volume will have to be grown manually\n", hostName)
+		return nil
+	}
+
+	mHost, err := metadata.LoadHost(client, hostID)
+	if err != nil || mHost == nil {
+		return fmt.Errorf("Host '%s' not found in metadatas", hostName)
+	}
+	hostVolumesV1 := propsv1.NewHostVolumes()
+	err = mHost.Get().Properties.Get(HostProperty.VolumesV1, hostVolumesV1)
+	if err != nil {
+		return fmt.Errorf("Failed to get host propertie hostVolumesV1 : %s", err.Error())
+	}
+	attachment, found := hostVolumesV1.VolumesByID[volume.ID]
+	if !found {
+		return fmt.Errorf("metadata inconsistency: volume '%s' not attached to host '%s'", volume.Name, hostName)
+	}
+
+	sshConfig, err := GetSSHConfigFromHostName(hostID)
+	if err != nil {
+		return fmt.Errorf("Failed get the sshConfig : %s", err.Error())
+	}
+	server, err := nfs.NewServer(sshConfig)
+	if err != nil {
+		return fmt.Errorf("Failed to creare the nfsServer : %s", err.Error())
+	}
+	err = server.ResizeBlockDevice(attachment.Device)
+	if err != nil {
+		return fmt.Errorf("Failed to resize the filesystem of the block device : %s", err.Error())
+	}
+	return nil
+}
+
+var volumeSnapshot = cli.Command{
+	Name:      "snapshot",
+	Usage:     "Create a point-in-time copy of a volume",
+	ArgsUsage: "<Volume_name|Volume_ID> <Snapshot_name>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			_ = cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Missing mandatory argument <Volume_name> and/or <Snapshot_name>")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mVolume, err := metadata.LoadVolume(client, c.Args().Get(0))
+		if err != nil {
+			return fmt.Errorf("Volume '%s' not found in metadatas", c.Args().Get(0))
+		}
+		volume := mVolume.Get()
+
+		snapshotRequest := model.VolumeSnapshotRequest{
+			Name:     c.Args().Get(1),
+			VolumeID: volume.ID,
+		}
+		snapshot, err := client.CreateVolumeSnapshot(snapshotRequest)
+		if err != nil {
+			return fmt.Errorf("Failed to snapshot volume '%s' : %s", volume.Name, err.Error())
+		}
+
+		err = metadata.SaveVolume(client, snapshot)
+		if err != nil {
+			return fmt.Errorf("Failed to save snapshot metadatas : %s", err.Error())
+		}
+
+		displayVolume(snapshot)
+
+		return nil
+	},
+}
+
+var volumeClone = cli.Command{
+	Name:      "clone",
+	Usage:     "Create a volume from a snapshot",
+	ArgsUsage: "<Volume_name>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "from-snapshot",
+			Value: "",
+			Usage: "Name or ID of the snapshot to clone",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			_ = cli.ShowSubcommandHelp(c)
+			return fmt.Errorf("Missing mandatory argument <Volume_name>")
+		}
+		if c.String("from-snapshot") == "" {
+			return fmt.Errorf("Missing mandatory flag --from-snapshot")
+		}
+
+		client, err := NewClient()
+		if err != nil {
+			return fmt.Errorf("Failed to get a new client : %s", err.Error())
+		}
+
+		mSnapshot, err := metadata.LoadVolume(client, c.String("from-snapshot"))
+		if err != nil {
+			return fmt.Errorf("Snapshot '%s' not found in metadatas", c.String("from-snapshot"))
+		}
+		snapshot := mSnapshot.Get()
+		snapshotDescriptionV2 := propsv2.NewVolumeDescription()
+		err = snapshot.Properties.Get(VolumeProperty.DescriptionV2, snapshotDescriptionV2)
+		if err != nil {
+			return fmt.Errorf("Failed to get volume propertie DescriptionV2 : %s", err.Error())
+		}
+		if !snapshotDescriptionV2.IsSnapshot {
+			return fmt.Errorf("Volume '%s' is not a snapshot", snapshot.Name)
+		}
+
+		cloneRequest := model.VolumeCloneRequest{
+			Name:       c.Args().First(),
+			SnapshotID: snapshot.ID,
+		}
+		clone, err := client.CreateVolumeClone(cloneRequest)
+		if err != nil {
+			return fmt.Errorf("Failed to clone snapshot '%s' : %s", snapshot.Name, err.Error())
+		}
+
+		err = metadata.SaveVolume(client, clone)
+		if err != nil {
+			return fmt.Errorf("Failed to save volume metadatas : %s", err.Error())
+		}
+
+		displayVolume(clone)
+
+		return nil
+	},
+}
+
 func displayVolume(volume *model.Volume) {
 	volumeAttachedV1 := propsv1.NewVolumeAttachments()
 	volume.Properties.Get(VolumeProperty.AttachedV1, volumeAttachedV1)
+	volumeDescriptionV2 := propsv2.NewVolumeDescription()
+	volume.Properties.Get(VolumeProperty.DescriptionV2, volumeDescriptionV2)
 
 	fmt.Println("\nVolume : ", volume.Name)
 	fmt.Println("	ID	: ", volume.ID)
@@ -440,6 +662,13 @@ func displayVolume(volume *model.Volume) {
 	fmt.Println("	Speed 	: ", volume.Speed)
 	fmt.Println("	State 	: ", volume.State)
 	fmt.Println("	Sharable: ", volumeAttachedV1.Shareable)
+	if volumeDescriptionV2.ParentID != "" {
+		if volumeDescriptionV2.IsSnapshot {
+			fmt.Println("	Snapshot of : ", volumeDescriptionV2.ParentName)
+		} else {
+			fmt.Println("	Cloned from : ", volumeDescriptionV2.ParentName)
+		}
+	}
 
 	for _, hostName := range volumeAttachedV1.Hosts {
 		fmt.Println("		Attached to host ", hostName)
@@ -482,3 +711,25 @@ func difference(slice1 []string, slice2 []string) []string {
 
 	return diffStr
 }
+
+// listClones returns the names of the volumes, known by metadata, backed by volume
+func listClones(client api.ClientAPI, volume *model.Volume) ([]string, error) {
+	clones := []string{}
+	mv := metadata.NewVolume(client)
+	err := mv.Browse(func(other *model.Volume) error {
+		otherDescriptionV2 := propsv2.NewVolumeDescription()
+		err := other.Properties.Get(VolumeProperty.DescriptionV2, otherDescriptionV2)
+		if err != nil {
+			return err
+		}
+		if otherDescriptionV2.ParentID == volume.ID && !otherDescriptionV2.IsSnapshot {
+			clones = append(clones, other.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return clones, nil
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	libvirt "github.com/libvirt/libvirt-go"
+)
+
+// addressSource finds the IP addresses of the interfaces of a domain
+type addressSource interface {
+	// Name returns the name of the source, as used in CfgOptions.AddressSources
+	Name() string
+	// Addresses returns the IP addresses of the domain, indexed by MAC address
+	Addresses(domain *libvirt.Domain) (map[string][]string, error)
+}
+
+// libvirtAddressSource is an addressSource relying on one of the address sources of libvirt
+type libvirtAddressSource struct {
+	name   string
+	source libvirt.DomainInterfaceAddressesSource
+}
+
+// Name returns the name of the source
+func (s *libvirtAddressSource) Name() string {
+	return s.name
+}
+
+// Addresses returns the IP addresses of the domain, indexed by MAC address
+func (s *libvirtAddressSource) Addresses(domain *libvirt.Domain) (map[string][]string, error) {
+	ifaces, err := domain.ListAllInterfaceAddresses(s.source)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list interface addresses from %s : %s", s.name, err.Error())
+	}
+
+	addresses := map[string][]string{}
+	for _, iface := range ifaces {
+		mac := strings.ToLower(iface.Hwaddr)
+		for _, addr := range iface.Addrs {
+			addresses[mac] = append(addresses[mac], addr.Addr)
+		}
+	}
+	return addresses, nil
+}
+
+// addressSources contains the known address sources, indexed by name
+var addressSources = map[string]addressSource{
+	// the qemu guest agent, running inside the domain, knows every address of the guest
+	"agent": &libvirtAddressSource{name: "agent", source: libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_AGENT},
+	// the DHCP leases of the libvirt networks only know the addresses of interfaces plugged on a libvirt network
+	"lease": &libvirtAddressSource{name: "lease", source: libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_LEASE},
+	// the neighbour table of the hypervisor is read passively, the guest must have talked to the hypervisor recently
+	"arp": &libvirtAddressSource{name: "arp", source: libvirt.DOMAIN_INTERFACE_ADDRESSES_SRC_ARP},
+}
+
+// defaultAddressSources lists the address sources used when none is configured, in order of preference
+var defaultAddressSources = []string{"agent", "lease", "arp"}
+
+// addressFamilies tells which address families an interface needs to be resolved
+type addressFamilies struct {
+	IPv4 bool
+	IPv6 bool
+}
+
+// isUsableAddress tells if ip is an address reachable from outside of its link
+// Link-local IPv6 addresses are configured on every interface, whatever the network
+func isUsableAddress(ip string) bool {
+	parsedIP := net.ParseIP(ip)
+	return parsedIP != nil && !parsedIP.IsLinkLocalUnicast()
+}
+
+// hasAddresses tells if ips contains a usable address of each of the families
+func hasAddresses(ips []string, families addressFamilies) bool {
+	hasIPv4, hasIPv6 := false, false
+	for _, ip := range ips {
+		if !isUsableAddress(ip) {
+			continue
+		}
+		if net.ParseIP(ip).To4() != nil {
+			hasIPv4 = true
+		} else {
+			hasIPv6 = true
+		}
+	}
+	return (hasIPv4 || !families.IPv4) && (hasIPv6 || !families.IPv6)
+}
+
+// getDomainAddresses queries the configured address sources in order, until every MAC address of required has a usable
+// address of each of its families, the addresses found by the successive sources are merged
+// Returns the usable IP addresses indexed by MAC address, and the name of the source having resolved each IP address
+func (client *Client) getDomainAddresses(domain *libvirt.Domain, required map[string]addressFamilies) (map[string][]string, map[string]string, error) {
+	addresses := map[string][]string{}
+	sources := map[string]string{}
+
+	for _, name := range client.Config.AddressSources {
+		source, ok := addressSources[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("Unknown address source '%s'", name)
+		}
+
+		resolved := true
+		for mac, families := range required {
+			if !hasAddresses(addresses[mac], families) {
+				resolved = false
+				break
+			}
+		}
+		if resolved {
+			break
+		}
+
+		found, err := source.Addresses(domain)
+		if err != nil {
+			// A source may be unavailable (no guest agent, no libvirt network...), the next one is tried
+			continue
+		}
+		for mac := range required {
+			for _, ip := range found[mac] {
+				if _, known := sources[ip]; known || !isUsableAddress(ip) {
+					continue
+				}
+				addresses[mac] = append(addresses[mac], ip)
+				sources[ip] = source.Name()
+			}
+		}
+	}
+
+	return addresses, sources, nil
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	libvirt "github.com/libvirt/libvirt-go"
+)
+
+const (
+	// linuxBridge is the type of the bridges managed by the kernel
+	linuxBridge = "linux"
+	// openvswitchBridge is the type of the bridges managed by Open vSwitch, it is also the type of their virtualport
+	openvswitchBridge = "openvswitch"
+)
+
+// networkPortDescription is the part of the xml description of a libvirt network describing the ports of the hosts
+type networkPortDescription struct {
+	XMLName     xml.Name `xml:"network"`
+	VirtualPort *struct {
+		Type string `xml:"type,attr"`
+	} `xml:"virtualport"`
+	VLAN *struct {
+		Tags []struct {
+			ID int `xml:"id,attr"`
+		} `xml:"tag"`
+	} `xml:"vlan"`
+}
+
+// getNetworkPortDescription returns the virtualport type and the VLAN tags of libvirtNetwork
+func getNetworkPortDescription(libvirtNetwork *libvirt.Network) (string, []int, error) {
+	networkXML, err := libvirtNetwork.GetXMLDesc(0)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed get xml description of a network : %s", err.Error())
+	}
+	portDescription := &networkPortDescription{}
+	err = xml.Unmarshal([]byte(networkXML), portDescription)
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed unmarshall the network description : %s", err.Error())
+	}
+
+	virtualPort := ""
+	if portDescription.VirtualPort != nil {
+		virtualPort = portDescription.VirtualPort.Type
+	}
+	vlans := []int{}
+	if portDescription.VLAN != nil {
+		for _, tag := range portDescription.VLAN.Tags {
+			vlans = append(vlans, tag.ID)
+		}
+	}
+	return virtualPort, vlans, nil
+}
+
+// getBridgeType returns the type (linuxBridge or openvswitchBridge) of the bridge of the hypervisor named name
+func getBridgeType(name string) (string, error) {
+	if _, err := os.Stat(filepath.Join("/sys/class/net", name)); err != nil {
+		return "", fmt.Errorf("There is no bridge '%s' on the hypervisor", name)
+	}
+	if _, err := os.Stat(filepath.Join("/sys/class/net", name, "bridge")); err == nil {
+		return linuxBridge, nil
+	}
+	if err := exec.Command("ovs-vsctl", "br-exists", name).Run(); err == nil {
+		return openvswitchBridge, nil
+	}
+	return "", fmt.Errorf("'%s' is neither a Linux bridge nor an Open vSwitch bridge", name)
+}
+
+// vlanXML returns the xml of the VLAN tags of a port, several tags make a trunk port
+func vlanXML(vlans []int) (string, error) {
+	if len(vlans) == 0 {
+		return "", nil
+	}
+
+	tagsXML := ""
+	for _, vlan := range vlans {
+		if vlan < 1 || vlan > 4094 {
+			return "", fmt.Errorf("Invalid VLAN tag %d, it must be between 1 and 4094", vlan)
+		}
+		tagsXML += `
+			<tag id="` + strconv.Itoa(vlan) + `"/>`
+	}
+	trunkXML := ""
+	if len(vlans) > 1 {
+		trunkXML = ` trunk="yes"`
+	}
+	return `
+		<vlan` + trunkXML + `>` + tagsXML + `
+		</vlan>`, nil
+}
+
+// bridgePortXML returns the xml of the virtualport and of the VLAN tags of the ports of a network plugged on the
+// existing bridge of the hypervisor named bridge, only the ports of an Open vSwitch bridge can be tagged
+func bridgePortXML(bridge string, vlans []int) (string, error) {
+	bridgeType, err := getBridgeType(bridge)
+	if err != nil {
+		return "", err
+	}
+	if bridgeType != openvswitchBridge {
+		if len(vlans) != 0 {
+			return "", fmt.Errorf("VLAN tags need an Open vSwitch bridge, '%s' is a Linux bridge", bridge)
+		}
+		return "", nil
+	}
+
+	vlansXML, err := vlanXML(vlans)
+	if err != nil {
+		return "", err
+	}
+	return `
+		<virtualport type="` + openvswitchBridge + `"/>` + vlansXML, nil
+}
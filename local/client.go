@@ -18,9 +18,11 @@ package local
 
 import (
 	"fmt"
+	"sync"
 
 	libvirt "github.com/libvirt/libvirt-go"
 	minio "github.com/minio/minio-go"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/CS-SI/LocalDriver/api"
 	"github.com/CS-SI/LocalDriver/metadata"
@@ -36,6 +38,29 @@ type Client struct {
 	AuthOptions *AuthOptions
 }
 
+// eventLoopOnce ensures the default event loop of libvirt is registered only once
+var eventLoopOnce sync.Once
+
+// startEventLoop registers and runs the default event loop of libvirt, needed to receive domain events
+// It has to be called before opening the connection to libvirt
+func startEventLoop() {
+	eventLoopOnce.Do(func() {
+		err := libvirt.EventRegisterDefaultImpl()
+		if err != nil {
+			log.Errorf("Failed to register the libvirt event loop : %s", err.Error())
+			return
+		}
+		go func() {
+			for {
+				err := libvirt.EventRunDefaultImpl()
+				if err != nil {
+					log.Errorf("Failed to run the libvirt event loop : %s", err.Error())
+				}
+			}
+		}()
+	})
+}
+
 type AuthOptions struct {
 }
 type CfgOptions struct {
@@ -45,6 +70,12 @@ type CfgOptions struct {
 	LanInterface              string
 	AutoHostNetworkInterfaces bool
 	UseLayer3Networking       bool
+	// AddressSources lists, in order of preference, the sources used to find the IP addresses of the hosts (agent, lease, arp)
+	AddressSources []string
+	// SupernetPool is the IPv4 range the subnets of the networks created without CIDR are taken from
+	SupernetPool string
+	// SubnetPrefix is the length of the prefix of the subnets taken from SupernetPool
+	SubnetPrefix int
 }
 
 //Create and initialize a ClientAPI
@@ -57,10 +88,14 @@ func (client *Client) Build(params map[string]interface{}) (api.ClientAPI, error
 			ProviderNetwork:           "default", //At least for KVM
 			AutoHostNetworkInterfaces: false,
 			UseLayer3Networking:       false,
+			AddressSources:            defaultAddressSources,
+			SupernetPool:              defaultSupernetPool,
+			SubnetPrefix:              defaultSubnetPrefix,
 		},
 		AuthOptions: &AuthOptions{},
 	}
 
+	startEventLoop()
 	libvirt, err := libvirt.NewConnect(params["uri"].(string))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to connect to libvirt : %s", err.Error())
@@ -85,6 +120,15 @@ func (client *Client) Build(params map[string]interface{}) (api.ClientAPI, error
 	}
 
 	clientAPI.Config.LanInterface = params["lanInterface"].(string)
+	if sources, ok := params["addressSources"].([]string); ok && len(sources) > 0 {
+		clientAPI.Config.AddressSources = sources
+	}
+	if pool, ok := params["supernetPool"].(string); ok && pool != "" {
+		clientAPI.Config.SupernetPool = pool
+	}
+	if prefix, ok := params["subnetPrefix"].(int); ok && prefix > 0 {
+		clientAPI.Config.SubnetPrefix = prefix
+	}
 
 	return clientAPI, nil
 }
@@ -104,6 +148,9 @@ func (client *Client) GetCfgOpts() (model.Config, error) {
 	config.Set("UseLayer3Networking", client.Config.UseLayer3Networking)
 	config.Set("MetadataBucket", client.Config.MetadataBucketName)
 	config.Set("ProviderNetwork", client.Config.ProviderNetwork)
+	config.Set("AddressSources", client.Config.AddressSources)
+	config.Set("SupernetPool", client.Config.SupernetPool)
+	config.Set("SubnetPrefix", client.Config.SubnetPrefix)
 
 	return config, nil
 }
@@ -26,15 +26,24 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/CS-SI/LocalDriver/metadata"
 	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/HostPowerMethod"
 	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	"github.com/CS-SI/LocalDriver/model/enums/HostRebootMode"
 	"github.com/CS-SI/LocalDriver/model/enums/HostState"
+	"github.com/CS-SI/LocalDriver/model/enums/IPVersion"
 	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+	propsv2 "github.com/CS-SI/LocalDriver/model/properties/v2"
 	"github.com/CS-SI/LocalDriver/userdata"
 	"github.com/CS-SI/LocalDriver/utils/retry"
 	libvirt "github.com/libvirt/libvirt-go"
@@ -44,10 +53,18 @@ import (
 )
 
 const imagesJsonPath string = "/home/armand/Iso/images.json"
+const imagesStorage string = "/home/armand/Iso"
 const templatesJsonPath string = "/home/armand/Iso/templates.json"
 const libvirtStorage string = "/home/armand/LibvirtStorage"
 const startupPath string = "/home/armand/go/src/github.com/CS-SI/LocalDriver/startup.sh"
 
+// hotplugFactor is the ratio between the maximal and the initial number of vCPUs (and amount of RAM) of a domain
+// this headroom allows to grow a running host without restarting it
+const hotplugFactor int = 2
+
+// addressResolutionTimeout is the time given to the address sources to find the IP addresses of a running host
+const addressResolutionTimeout time.Duration = 2 * time.Minute
+
 //-------------IMAGES---------------------------------------------------------------------------------------------------
 
 // ListImages lists available OS images
@@ -118,6 +135,127 @@ func (client *Client) GetImage(id string) (*model.Image, error) {
 	return nil, fmt.Errorf("Image with id=%s not found", id)
 }
 
+// imageNameRegexp restricts the names of captured images as they are used in file paths and shell commands
+var imageNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// registerImage adds an image to the images.json catalog
+func registerImage(image *model.Image, imagePath string) error {
+	byteValue, err := ioutil.ReadFile(imagesJsonPath)
+	if err != nil {
+		return fmt.Errorf("Failed to read %s : %s", imagesJsonPath, err.Error())
+	}
+
+	var result map[string]interface{}
+	err = json.Unmarshal(byteValue, &result)
+	if err != nil {
+		return fmt.Errorf("Failed to unmarshal %s : %s", imagesJsonPath, err.Error())
+	}
+
+	imagesJson, _ := result["images"].([]interface{})
+	result["images"] = append(imagesJson, map[string]interface{}{
+		"imageID":   image.ID,
+		"imageName": image.Name,
+		"imagePath": imagePath,
+	})
+
+	byteValue, err = json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal %s : %s", imagesJsonPath, err.Error())
+	}
+	err = ioutil.WriteFile(imagesJsonPath, byteValue, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s : %s", imagesJsonPath, err.Error())
+	}
+
+	return nil
+}
+
+// CaptureImage creates a new image from the disk of a host and registers it in the images catalog
+// If the host is not stopped during the capture, the copy is only crash-consistent
+func (client *Client) CaptureImage(request model.ImageCaptureRequest) (*model.Image, error) {
+	if request.Name == "" {
+		return nil, fmt.Errorf("The image Name is mandatory")
+	}
+	if !imageNameRegexp.MatchString(request.Name) {
+		return nil, fmt.Errorf("Invalid image name %s, only letters, digits, '.', '_' and '-' are allowed", request.Name)
+	}
+	images, err := client.ListImages(true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list images : %s", err.Error())
+	}
+	for _, image := range images {
+		if image.Name == request.Name {
+			return nil, fmt.Errorf("The image %s already exists", request.Name)
+		}
+	}
+	imagePath := fmt.Sprintf("%s/%s.qcow2", imagesStorage, request.Name)
+	if _, err := os.Stat(imagePath); !os.IsNotExist(err) {
+		return nil, fmt.Errorf("The file %s already exists", imagePath)
+	}
+
+	domain, err := client.getDomainFromRef(request.HostID)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+	rootVolume, err := getRootVolumeFromDomain(domain, client.LibvirtService)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := domain.IsActive()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to know if the domain is active : %s", err.Error())
+	}
+	if active && request.Shutdown {
+		_, err = stopDomain(domain, 5*time.Minute, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The image is built aside and only moved into place once registered
+	tmpImagePath := fmt.Sprintf("%s/.%s.qcow2.tmp", imagesStorage, request.Name)
+
+	// -U allows to read the disk of a running domain
+	commands := []string{fmt.Sprintf("qemu-img convert -U -O qcow2 '%s' '%s'", rootVolume.Key, tmpImagePath)}
+	if request.Sysprep {
+		commands = append(commands, fmt.Sprintf("virt-sysprep -a '%s' --operations defaults,user-account --remove-user-accounts %s --delete /etc/sudoers.d/%s --delete '/var/tmp/user_data.*'", tmpImagePath, model.DefaultUser, model.DefaultUser))
+	}
+	commands = append(commands, fmt.Sprintf("chmod 666 '%s'", tmpImagePath))
+	command := strings.Join(commands, " && ")
+
+	cmd := exec.Command("bash", "-c", command)
+	cmdOutput := &bytes.Buffer{}
+	cmd.Stdout = cmdOutput
+	err = cmd.Run()
+	if active && request.Shutdown {
+		if startErr := domain.Create(); startErr != nil {
+			os.Remove(tmpImagePath)
+			return nil, fmt.Errorf("Failed to restart the host : %s", startErr.Error())
+		}
+	}
+	if err != nil {
+		os.Remove(tmpImagePath)
+		return nil, fmt.Errorf("Commands failled : %s\n%s", command, err.Error())
+	}
+
+	image := &model.Image{
+		ID:   uuid.NewV4().String(),
+		Name: request.Name,
+	}
+	err = registerImage(image, imagePath)
+	if err != nil {
+		os.Remove(tmpImagePath)
+		return nil, err
+	}
+	err = os.Rename(tmpImagePath, imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to move %s to %s : %s", tmpImagePath, imagePath, err.Error())
+	}
+
+	return image, nil
+}
+
 //-------------TEMPLATES------------------------------------------------------------------------------------------------
 
 // ListTemplates overload OpenStack ListTemplate method to filter wind and flex instance and add GPU configuration
@@ -321,20 +459,6 @@ func getVolumesFromDomain(domain *libvirt.Domain, libvirtService *libvirt.Connec
 	return volumeDescriptions, nil
 }
 
-//stateConvert convert libvirt.DomainState to a HostState.Enum
-func stateConvert(stateLibvirt libvirt.DomainState) HostState.Enum {
-	switch stateLibvirt {
-	case 1:
-		return HostState.STARTED
-	case 3, 5:
-		return HostState.STOPPED
-	case 4:
-		return HostState.STOPPING
-	default:
-		return HostState.ERROR
-	}
-}
-
 // TODO implement the getDescriptionV1FromDomain
 func getDescriptionV1FromDomain(domain *libvirt.Domain, libvirtService *libvirt.Connect) (*propsv1.HostDescription, error) {
 	hostDescription := propsv1.NewHostDescription()
@@ -365,7 +489,7 @@ func getSizingV1FromDomain(domain *libvirt.Domain, libvirtService *libvirt.Conne
 		diskSize += int(volume.Capacity.Value / 1024 / 1024 / 1024)
 	}
 
-	hostSizing.AllocatedSize.RAMSize = float32(info.MaxMem) / 1024 / 1024
+	hostSizing.AllocatedSize.RAMSize = float32(info.Memory) / 1024 / 1024
 	hostSizing.AllocatedSize.Cores = int(info.NrVirtCpu)
 	hostSizing.AllocatedSize.DiskSize = diskSize
 	// TODO GPU not implemented
@@ -376,8 +500,12 @@ func getSizingV1FromDomain(domain *libvirt.Domain, libvirtService *libvirt.Conne
 
 	return hostSizing, nil
 }
-func (client *Client) getNetworkV1FromDomain(domain *libvirt.Domain) (*propsv1.HostNetwork, error) {
-	hostNetwork := propsv1.NewHostNetwork()
+
+// getNetworkFromDomain resolves the networks and the IP addresses of the interfaces of a domain
+// The address sources are queried until every interface has an address or until timeout, only once if timeout is 0;
+// an inactive domain has no address
+func (client *Client) getNetworkFromDomain(domain *libvirt.Domain, timeout time.Duration) (*propsv2.HostNetwork, error) {
+	hostNetwork := propsv2.NewHostNetwork()
 
 	domainXML, err := domain.GetXMLDesc(0)
 	if err != nil {
@@ -385,84 +513,106 @@ func (client *Client) getNetworkV1FromDomain(domain *libvirt.Domain) (*propsv1.H
 	}
 	domainDescription := &libvirtxml.Domain{}
 	err = xml.Unmarshal([]byte(domainXML), domainDescription)
-
-	networks, err := client.LibvirtService.ListAllNetworks(3)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to list all networks : %s", err.Error())
+		return nil, fmt.Errorf(fmt.Sprintf("Failed unmarshall the domain description : %s", err.Error()))
 	}
 
+	// The interfaces of the hosts need an address of each family of their network, IPv4 for the public ones
+	required := map[string]addressFamilies{}
 	for _, iface := range domainDescription.Devices.Interfaces {
+		if iface.MAC == nil || iface.Source == nil {
+			continue
+		}
+		families := addressFamilies{IPv4: true}
 		if iface.Source.Network != nil {
-			err = retry.WhileUnsuccessfulDelay5Seconds(
-				func() error {
-					for _, network := range networks {
-						name, err := network.GetName()
-						if err != nil {
-							return fmt.Errorf("Failed to get network name : %s", err.Error())
-						}
-						if name == iface.Source.Network.Network {
-							dhcpLeases, err := network.GetDHCPLeases()
-							if err != nil {
-								return fmt.Errorf("Failed to get network dhcpLeases : %s", err.Error())
-							}
-							for _, dhcpLease := range dhcpLeases {
-								if dhcpLease.Mac == iface.MAC.Address {
-									net, err := client.GetNetwork(iface.Source.Network.Network)
-									if err != nil {
-										return fmt.Errorf("Unknown Network %s", iface.Source.Network.Network)
-									}
-									if len(strings.Split(dhcpLease.IPaddr, ".")) == 4 {
-										hostNetwork.IPv4Addresses[net.ID] = dhcpLease.IPaddr
-									} else if len(strings.Split(dhcpLease.IPaddr, ":")) == 8 {
-										hostNetwork.IPv6Addresses[net.ID] = dhcpLease.IPaddr
-									} else {
-										return fmt.Errorf("Unknown adressType")
-									}
-									hostNetwork.NetworksByID[net.ID] = net.Name
-									hostNetwork.NetworksByName[net.Name] = net.ID
-									return nil
-								}
-							}
-						}
-					}
-					return fmt.Errorf("No local IP matching inteface %s found", iface.Alias)
-				},
-				5*time.Minute,
-			)
-
-		}
-		if iface.Source.Direct != nil {
-			var ip string
-			err = retry.WhileUnsuccessfulDelay5Seconds(
-				func() error {
-					//TODO dynamic ip range
-					cmd := exec.Command("bash", "-c", "sleep 30 && nmap -T5 -sP --host-timeout 1 172.26.128.0/24 > /dev/null && arp | grep "+iface.MAC.Address+" | cut -f1 -d\\ ")
-					cmdOutput := &bytes.Buffer{}
-					cmd.Stdout = cmdOutput
-					err = cmd.Run()
-					if err != nil {
-						return fmt.Errorf("Commands failled : ", err.Error())
-					}
-					ip = strings.Trim(fmt.Sprintf("%s", cmdOutput), " \n")
-					if len(strings.Split(ip, ".")) == 4 {
-						hostNetwork.PublicIPv4 = ip
-					} else if len(strings.Split(ip, ":")) == 8 {
-						hostNetwork.PublicIPv6 = ip
-					} else {
-						return fmt.Errorf("Unknown adressType")
-					}
-					return nil
-				},
-				5*time.Minute,
-			)
+			net, err := client.GetNetwork(iface.Source.Network.Network)
+			if err != nil {
+				return nil, fmt.Errorf("Unknown Network %s", iface.Source.Network.Network)
+			}
+			hostNetwork.NetworksByID[net.ID] = net.Name
+			hostNetwork.NetworksByName[net.Name] = net.ID
+			families = addressFamilies{
+				IPv4: net.IPVersion == IPVersion.IPv4,
+				IPv6: net.IPVersion == IPVersion.IPv6 || net.IPv6CIDR != "",
+			}
+		}
+		required[strings.ToLower(iface.MAC.Address)] = families
+	}
+
+	active, err := domain.IsActive()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to know if the domain is active : %s", err.Error())
+	}
+	if !active || len(required) == 0 {
+		hostNetwork.ResolvedAt = time.Now()
+		return hostNetwork, nil
+	}
+
+	var addresses map[string][]string
+	var sources map[string]string
+	resolve := func() error {
+		addresses, sources, err = client.getDomainAddresses(domain, required)
+		if err != nil {
+			return err
+		}
+		for mac, families := range required {
+			if !hasAddresses(addresses[mac], families) {
+				return fmt.Errorf("No IP address found for interface %s", mac)
+			}
+		}
+		return nil
+	}
+	if timeout > 0 {
+		// A timeout only means that some interfaces have no address yet, what has been found is kept
+		_ = retry.WhileUnsuccessfulDelay5Seconds(resolve, timeout)
+	} else {
+		// A zero timeout would make the retry endless, the addresses are looked up once
+		_ = resolve()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range domainDescription.Devices.Interfaces {
+		if iface.MAC == nil || iface.Source == nil {
+			continue
+		}
+		for _, ip := range addresses[strings.ToLower(iface.MAC.Address)] {
+			parsedIP := net.ParseIP(ip)
+			// Link-local IPv6 addresses are configured on every interface and are not reachable from another network
+			if parsedIP == nil || parsedIP.IsLinkLocalUnicast() {
+				continue
+			}
+			isIPv4 := parsedIP.To4() != nil
+			if iface.Source.Network != nil {
+				netID := hostNetwork.NetworksByName[iface.Source.Network.Network]
+				if isIPv4 {
+					hostNetwork.IPv4Addresses[netID] = ip
+				} else {
+					hostNetwork.IPv6Addresses[netID] = ip
+				}
+			} else if iface.Source.Direct != nil {
+				if isIPv4 {
+					hostNetwork.PublicIPv4 = ip
+				} else {
+					hostNetwork.PublicIPv6 = ip
+				}
+			} else {
+				continue
+			}
+			hostNetwork.AddressSources[ip] = sources[ip]
 		}
 	}
+	hostNetwork.ResolvedAt = time.Now()
+
 	return hostNetwork, nil
 }
 
-//TODO propose a version without the properties(time consuming)
 // getHostFromDomain build a model.Host struct representing a Domain
-func (client *Client) getHostFromDomain(domain *libvirt.Domain) (*model.Host, error) {
+// State and sizing come from the domain, the other properties from the cached metadata of the host (if any).
+// The IP addresses are taken from the cache, unless refresh is set or nothing is cached yet;
+// when refreshing, the address sources of a running domain are given addressResolutionTimeout to answer
+func (client *Client) getHostFromDomain(domain *libvirt.Domain, refresh bool) (*model.Host, error) {
 	id, err := domain.GetUUIDString()
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("Failed to fetch id from domain : %s", err.Error()))
@@ -471,47 +621,131 @@ func (client *Client) getHostFromDomain(domain *libvirt.Domain) (*model.Host, er
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("Failed to fetch name from domain : %s", err.Error()))
 	}
-	state, _, err := domain.GetState()
+	state, reason, err := domain.GetState()
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("Failed to fetch state from domain : %s", err.Error()))
 	}
-	hostDescriptionV1, err := getDescriptionV1FromDomain(domain, client.LibvirtService)
-	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("Failed to get domain description : %s", err.Error()))
-	}
 	hostSizingV1, err := getSizingV1FromDomain(domain, client.LibvirtService)
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("Failed to get domain sizing : %s", err.Error()))
 	}
-	hostNetworkV1, err := client.getNetworkV1FromDomain(domain)
+
+	host := model.NewHost()
+	host.PrivateKey = "Impossible to fetch them from the domain, the private key is unknown by the domain for security reasons"
+	mHost, err := metadata.LoadHostByID(client, id)
+	if err == nil && mHost != nil {
+		host = mHost.Get()
+	} else {
+		hostDescriptionV1, err := getDescriptionV1FromDomain(domain, client.LibvirtService)
+		if err != nil {
+			return nil, fmt.Errorf(fmt.Sprintf("Failed to get domain description : %s", err.Error()))
+		}
+		host.Properties.Set(HostProperty.DescriptionV1, hostDescriptionV1)
+	}
+
+	// The requested sizing and the template are unknown by libvirt, they are kept from the cache
+	cachedSizingV1 := propsv1.NewHostSizing()
+	host.Properties.Get(HostProperty.SizingV1, cachedSizingV1)
+	hostSizingV1.RequestedSize = cachedSizingV1.RequestedSize
+	hostSizingV1.Template = cachedSizingV1.Template
+
+	hostHibernationV1 := propsv1.NewHostHibernation()
+	host.Properties.Get(HostProperty.HibernationV1, hostHibernationV1)
+	hostHibernationV1, err = client.getHibernationV1FromDomain(domain, hostHibernationV1)
 	if err != nil {
-		return nil, fmt.Errorf(fmt.Sprintf("Failed to get domain networks: %s", err.Error()))
+		return nil, fmt.Errorf(fmt.Sprintf("Failed to get domain hibernation : %s", err.Error()))
 	}
 
-	host := model.NewHost()
+	hostNetworkV2 := propsv2.NewHostNetwork()
+	host.Properties.Get(HostProperty.NetworkV2, hostNetworkV2)
+	if refresh || hostNetworkV2.ResolvedAt.IsZero() {
+		// Without refresh, the addresses are looked up once to stay fast, missing ones are resolved by the next refresh
+		timeout := time.Duration(0)
+		if refresh {
+			timeout = addressResolutionTimeout
+		}
+		resolvedNetworkV2, err := client.getNetworkFromDomain(domain, timeout)
+		if err != nil {
+			return nil, fmt.Errorf(fmt.Sprintf("Failed to get domain networks: %s", err.Error()))
+		}
+		// The role of the host in the network is unknown by libvirt, it is kept from the cache
+		resolvedNetworkV2.IsGateway = hostNetworkV2.IsGateway
+		resolvedNetworkV2.DefaultGatewayID = hostNetworkV2.DefaultGatewayID
+		resolvedNetworkV2.DefaultGatewayPrivateIP = hostNetworkV2.DefaultGatewayPrivateIP
+		resolvedNetworkV2.DefaultNetworkID = hostNetworkV2.DefaultNetworkID
+		hostNetworkV2 = resolvedNetworkV2
+	}
 
 	host.ID = id
 	host.Name = name
-	host.PrivateKey = "Impossible to fetch them from the domain, the private key is unknown by the domain for security reasons"
-	host.LastState = stateConvert(state)
-	host.Properties.Set(HostProperty.DescriptionV1, hostDescriptionV1)
+	host.LastState, host.LastStateReason = stateConvert(state, reason)
+	host.LastStateReasonCode = reason
 	host.Properties.Set(HostProperty.SizingV1, hostSizingV1)
-	host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+	host.Properties.Set(HostProperty.NetworkV1, hostNetworkV2.HostNetwork)
+	host.Properties.Set(HostProperty.NetworkV2, hostNetworkV2)
+	host.Properties.Set(HostProperty.HibernationV1, hostHibernationV1)
 
 	return host, nil
 }
 
-// getHostAndDomainFromRef retrieve the host and the domain associated to an ref (id or name)
-func (client *Client) getHostAndDomainFromRef(ref string) (*model.Host, *libvirt.Domain, error) {
+// getDomainDescription returns the xml description of domain
+func getDomainDescription(domain *libvirt.Domain) (*libvirtxml.Domain, error) {
+	domainXML, err := domain.GetXMLDesc(0)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed get xml description of a domain : %s", err.Error()))
+	}
+	domainDescription := &libvirtxml.Domain{}
+	err = xml.Unmarshal([]byte(domainXML), domainDescription)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed unmarshall the domain description : %s", err.Error()))
+	}
+
+	return domainDescription, nil
+}
+
+// domainModificationFlags returns the flags applying a device modification to the running domain and to its persistent definition
+func domainModificationFlags(domain *libvirt.Domain) (libvirt.DomainDeviceModifyFlags, error) {
+	var flags libvirt.DomainDeviceModifyFlags
+
+	active, err := domain.IsActive()
+	if err != nil {
+		return flags, fmt.Errorf("Failed to get domain state : %s", err.Error())
+	}
+	persistent, err := domain.IsPersistent()
+	if err != nil {
+		return flags, fmt.Errorf("Failed to know if the domain is persistent : %s", err.Error())
+	}
+
+	if active {
+		flags |= libvirt.DOMAIN_DEVICE_MODIFY_LIVE
+	}
+	if persistent {
+		flags |= libvirt.DOMAIN_DEVICE_MODIFY_CONFIG
+	}
+	return flags, nil
+}
+
+// getDomainFromRef retrieve the domain associated to an ref (id or name)
+func (client *Client) getDomainFromRef(ref string) (*libvirt.Domain, error) {
 	domain, err := client.LibvirtService.LookupDomainByUUIDString(ref)
 	if err != nil {
 		domain, err = client.LibvirtService.LookupDomainByName(ref)
 		if err != nil {
-			return nil, nil, fmt.Errorf(fmt.Sprintf("Failed to fetch domain from ref : %s", err.Error()))
+			return nil, fmt.Errorf(fmt.Sprintf("Failed to fetch domain from ref : %s", err.Error()))
 		}
 	}
 
-	host, err := client.getHostFromDomain(domain)
+	return domain, nil
+}
+
+// getHostAndDomainFromRef retrieve the host and the domain associated to an ref (id or name)
+func (client *Client) getHostAndDomainFromRef(ref string, refresh bool) (*model.Host, *libvirt.Domain, error) {
+	domain, err := client.getDomainFromRef(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host, err := client.getHostFromDomain(domain, refresh)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Failed to get host from domain : %s", err.Error())
 	}
@@ -549,10 +783,24 @@ func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error)
 	if imageID == "" {
 		return nil, fmt.Errorf("The ImageID is mandatory", resourceName)
 	}
-	host, _, err := client.getHostAndDomainFromRef(resourceName)
-	if err == nil && host != nil {
+	domain, err := client.getDomainFromRef(resourceName)
+	if err == nil && domain != nil {
 		return nil, fmt.Errorf("The Host %s already exists", resourceName)
 	}
+	for _, network := range networks {
+		ip, ok := request.IPAddresses[network.ID]
+		if !ok {
+			continue
+		}
+		libvirtNetwork, err := getNetworkFromRef(network.ID, client.LibvirtService)
+		if err != nil {
+			return nil, err
+		}
+		err = checkAddressAvailable(libvirtNetwork, ip)
+		if err != nil {
+			return nil, fmt.Errorf("The IP address %s can't be given to the host %s : %s", ip, resourceName, err.Error())
+		}
+	}
 
 	//----Initialize----
 	if keyPair == nil {
@@ -581,9 +829,16 @@ func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error)
 	}
 
 	//----Commands----
+	// The MAC addresses are chosen here to reserve the requested IP addresses before the first boot of the host
+	macs := map[string]string{}
 	networksCommandString := ""
 	for _, network := range networks {
-		networksCommandString += fmt.Sprintf(" --network network=%s", network.Name)
+		mac, err := generateMAC()
+		if err != nil {
+			return nil, err
+		}
+		macs[network.ID] = mac
+		networksCommandString += fmt.Sprintf(" --network network=%s,mac=%s", network.Name, mac)
 	}
 	if publicIP {
 		networksCommandString += fmt.Sprintf(" --network type=direct,source=%s,source_mode=bridge", client.Config.LanInterface)
@@ -596,15 +851,38 @@ func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error)
 	command_copy := fmt.Sprintf("cd $LIBVIRT_STORAGE && cp $IMAGE_PATH . && chmod 666 $IMAGE")
 	command_resize := fmt.Sprintf("truncate $VM_IMAGE -s %dG && virt-resize --expand /dev/sda1 $IMAGE $VM_IMAGE && rm $IMAGE", template.DiskSize)
 	command_sysprep := fmt.Sprintf("virt-sysprep -a $VM_IMAGE --hostname %s --operations all,-ssh-hostkeys --firstboot %s_userdata.sh && rm %s_userdata.sh", hostName, resourceName, resourceName)
-	command_virt_install := fmt.Sprintf("virt-install --name=%s --vcpus=%d --memory=%d --import --disk=$VM_IMAGE %s --noautoconsole", resourceName, template.Cores, int(template.RAMSize*1024), networksCommandString)
+	command_virt_install := fmt.Sprintf("virt-install --name=%s --vcpus=%d,maxvcpus=%d --memory=%d,maxmemory=%d --import --disk=$VM_IMAGE %s --serial pty --console pty,target_type=serial --noautoconsole", resourceName, template.Cores, template.Cores*hotplugFactor, int(template.RAMSize*1024), int(template.RAMSize*1024)*hotplugFactor, networksCommandString)
 	command := strings.Join([]string{command_setup, command_copy, command_resize, command_sysprep, command_virt_install}, " && ")
 
+	reservations := map[*libvirt.Network]string{}
+	releaseReservations := func() {
+		for libvirtNetwork, mac := range reservations {
+			_ = removeReservations(libvirtNetwork, mac)
+		}
+	}
+	for _, network := range networks {
+		ip, ok := request.IPAddresses[network.ID]
+		if !ok {
+			continue
+		}
+		libvirtNetwork, err := getNetworkFromRef(network.ID, client.LibvirtService)
+		if err == nil {
+			err = reserveAddress(libvirtNetwork, macs[network.ID], ip)
+		}
+		if err != nil {
+			releaseReservations()
+			return nil, fmt.Errorf("Failed to reserve the IP address %s : %s", ip, err.Error())
+		}
+		reservations[libvirtNetwork] = macs[network.ID]
+	}
+
 	cmd := exec.Command("bash", "-c", command)
 
 	cmdOutput := &bytes.Buffer{}
 	cmd.Stdout = cmdOutput
 	err = cmd.Run()
 	if err != nil {
+		releaseReservations()
 		return nil, fmt.Errorf("Commands failled : \n", command, "\n", err.Error())
 	}
 
@@ -615,20 +893,30 @@ func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error)
 	}()
 
 	//----Generate model.Host----
-	domain, err := client.LibvirtService.LookupDomainByName(resourceName)
+	domain, err = client.LibvirtService.LookupDomainByName(resourceName)
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("Can't find domain %s : %s", resourceName, err.Error()))
 	}
 
-	host, err = client.getHostFromDomain(domain)
+	host, err := client.getHostFromDomain(domain, true)
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("Failed to get host %s from domain : %s", resourceName, err.Error()))
 	}
 
 	host.PrivateKey = keyPair.PrivateKey
 
-	hostNetworkV1 := propsv1.NewHostNetwork()
-	host.Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+	hostnames := []string{resourceName}
+	if hostName != resourceName {
+		hostnames = append(hostnames, hostName)
+	}
+	err = client.addHostDNSRecords(host, hostnames)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed to register host %s in the DNS of its networks : %s", resourceName, err.Error()))
+	}
+
+	hostNetworkV2 := propsv2.NewHostNetwork()
+	host.Properties.Get(HostProperty.NetworkV2, hostNetworkV2)
+	hostNetworkV1 := hostNetworkV2.HostNetwork
 
 	hostNetworkV1.DefaultNetworkID = request.Networks[0].ID
 	hostNetworkV1.IsGateway = request.DefaultGateway == nil && request.Networks[0].Name != model.SingleHostNetworkName
@@ -641,6 +929,10 @@ func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error)
 		}
 
 		hostNetworkV1.DefaultGatewayPrivateIP = gateway.GetPrivateIP()
+		if vip := request.Networks[0].GatewayVIP; vip != "" {
+			// The traffic goes through the gateway holding the virtual IP of highly available gateways
+			hostNetworkV1.DefaultGatewayPrivateIP = vip
+		}
 	}
 
 	hostSizingV1 := propsv1.NewHostSizing()
@@ -654,24 +946,37 @@ func (client *Client) CreateHost(request model.HostRequest) (*model.Host, error)
 	hostSizingV1.RequestedSize.GPUType = template.GPUType
 
 	host.Properties.Set(HostProperty.NetworkV1, hostNetworkV1)
+	host.Properties.Set(HostProperty.NetworkV2, hostNetworkV2)
 	host.Properties.Set(HostProperty.SizingV1, hostSizingV1)
 
 	return host, nil
 }
 
-func (client *Client) GetHost(hostParam interface{}) (*model.Host, error) {
-	var ref string
-
+// hostRefFromParam returns the reference (id or name) of the host designated by hostParam
+func hostRefFromParam(hostParam interface{}) string {
 	switch hostParam.(type) {
 	case string:
-		ref = hostParam.(string)
+		return hostParam.(string)
 	case *model.Host:
-		ref = hostParam.(*model.Host).ID
+		return hostParam.(*model.Host).ID
 	default:
 		panic("host must be a string or a *model.Host!")
 	}
+}
 
-	host, _, err := client.getHostAndDomainFromRef(ref)
+// GetHost returns the host identified by hostParam, with the IP addresses cached in metadata
+func (client *Client) GetHost(hostParam interface{}) (*model.Host, error) {
+	host, _, err := client.getHostAndDomainFromRef(hostRefFromParam(hostParam), false)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("getHostAndDomainFromRef failed : %s", err.Error()))
+	}
+
+	return host, nil
+}
+
+// RefreshHost returns the host identified by hostParam, with its IP addresses resolved again
+func (client *Client) RefreshHost(hostParam interface{}) (*model.Host, error) {
+	host, _, err := client.getHostAndDomainFromRef(hostRefFromParam(hostParam), true)
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("getHostAndDomainFromRef failed : %s", err.Error()))
 	}
@@ -685,9 +990,9 @@ func (client *Client) GetHostByName(name string) (*model.Host, error) {
 
 // DeleteHost deletes the host identified by id
 func (client *Client) DeleteHost(id string) error {
-	_, domain, err := client.getHostAndDomainFromRef(id)
+	domain, err := client.getDomainFromRef(id)
 	if err != nil {
-		return fmt.Errorf(fmt.Sprintf("getHostAndDomainFromRef failed : %s", err.Error()))
+		return fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
 	}
 
 	volumes, err := getVolumesFromDomain(domain, client.LibvirtService)
@@ -695,6 +1000,16 @@ func (client *Client) DeleteHost(id string) error {
 		return fmt.Errorf(fmt.Sprintf("Failed to get the volumes from the domain : %s", err.Error()))
 	}
 
+	domainName, err := domain.GetName()
+	if err != nil {
+		return fmt.Errorf("Failed to get domain name : %s", err.Error())
+	}
+	// The interfaces are read before the domain is undefined, to clean up the networks afterwards
+	domainDescription, err := getDomainDescription(domain)
+	if err != nil {
+		return err
+	}
+
 	err = domain.Destroy()
 	if err != nil {
 		return fmt.Errorf(fmt.Sprintf("Failed to destroy the domain : %s", err.Error()))
@@ -703,15 +1018,23 @@ func (client *Client) DeleteHost(id string) error {
 	if err != nil {
 		return fmt.Errorf(fmt.Sprintf("Failed to undefine the domain : %s", err.Error()))
 	}
+	err = client.removeHostFilter(domainName)
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("Failed to remove the security groups of the domain : %s", err.Error()))
+	}
+	err = client.removeDomainDNSRecords(domainDescription, domainName)
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("Failed to remove the DNS records of the domain : %s", err.Error()))
+	}
+	err = client.removeDomainReservations(domainDescription)
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("Failed to remove the DHCP reservations of the domain : %s", err.Error()))
+	}
 
 	for _, volume := range volumes {
 		volumePath := volume.Key
 		pathSplitted := strings.Split(volumePath, "/")
 		volumeName := strings.Split(pathSplitted[len(pathSplitted)-1], ".")[0]
-		domainName, err := domain.GetName()
-		if err != nil {
-			return fmt.Errorf("Failed to get domain name : %s", err.Error())
-		}
 		if domainName == volumeName {
 			err := os.Remove(volumePath)
 			if err != nil {
@@ -723,46 +1046,83 @@ func (client *Client) DeleteHost(id string) error {
 	return nil
 }
 
-// ListHosts lists available hosts
+// ListHosts lists available hosts, the domains are queried concurrently
 func (client *Client) ListHosts() ([]*model.Host, error) {
-	var hosts []*model.Host
-
 	domains, err := client.LibvirtService.ListAllDomains(16383)
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("Error listing domains : %s", err.Error()))
 	}
-	for _, domain := range domains {
-		host, err := client.getHostFromDomain(&domain)
+
+	hosts := make([]*model.Host, len(domains))
+	errs := make([]error, len(domains))
+	var wg sync.WaitGroup
+	for i := range domains {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			hosts[i], errs[i] = client.getHostFromDomain(&domains[i], false)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
 			return nil, fmt.Errorf(fmt.Sprintf("Failed to get host from domain : %s", err.Error()))
 		}
-
-		hosts = append(hosts, host)
 	}
 
 	return hosts, nil
 }
 
-// StopHost stops the host identified by id
-func (client *Client) StopHost(id string) error {
-	_, domain, err := client.getHostAndDomainFromRef(id)
+// stopDomain shuts the domain down and waits until it is shut off, the domain is destroyed if force is set and timeout is reached
+// With a timeout of 0, returns as soon as the shutdown has been requested, or destroys the domain at once if force is set
+func stopDomain(domain *libvirt.Domain, timeout time.Duration, force bool) (HostPowerMethod.Enum, error) {
+	state, _, err := domain.GetState()
 	if err != nil {
-		return fmt.Errorf(fmt.Sprintf("getHostAndDomainFromRef failed : %s", err.Error()))
+		return HostPowerMethod.NONE, fmt.Errorf(fmt.Sprintf("Failed to fetch state from domain : %s", err.Error()))
+	}
+	if state == libvirt.DOMAIN_SHUTOFF {
+		return HostPowerMethod.NONE, nil
 	}
 
-	err = domain.Shutdown()
+	if timeout != 0 || !force {
+		err = domain.Shutdown()
+		if err == nil {
+			if timeout == 0 {
+				return HostPowerMethod.GRACEFUL, nil
+			}
+			err = waitDomainState(domain, libvirt.DOMAIN_SHUTOFF, timeout)
+			if err == nil {
+				return HostPowerMethod.GRACEFUL, nil
+			}
+		}
+		if !force {
+			return HostPowerMethod.NONE, fmt.Errorf("Failed to shutdown the host within %s : %s", timeout, err.Error())
+		}
+	}
+
+	err = domain.Destroy()
 	if err != nil {
-		return fmt.Errorf(fmt.Sprintf("Failed to shutdown the host : %s", err.Error()))
+		return HostPowerMethod.NONE, fmt.Errorf(fmt.Sprintf("Failed to destroy the domain : %s", err.Error()))
 	}
+	return HostPowerMethod.FORCED, nil
+}
 
-	return nil
+// StopHost stops the host identified by request.HostID and tells how it has been stopped
+func (client *Client) StopHost(request model.HostStopRequest) (HostPowerMethod.Enum, error) {
+	domain, err := client.getDomainFromRef(request.HostID)
+	if err != nil {
+		return HostPowerMethod.NONE, fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+
+	return stopDomain(domain, request.Timeout, request.Force)
 }
 
 // StartHost starts the host identified by id
 func (client *Client) StartHost(id string) error {
-	_, domain, err := client.getHostAndDomainFromRef(id)
+	domain, err := client.getDomainFromRef(id)
 	if err != nil {
-		return fmt.Errorf(fmt.Sprintf("getHostAndDomainFromRef failed : %s", err.Error()))
+		return fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
 	}
 
 	err = domain.Create()
@@ -775,30 +1135,377 @@ func (client *Client) StartHost(id string) error {
 	return nil
 }
 
-// RebootHost reboot the host identified by id
-func (client *Client) RebootHost(id string) error {
-	_, domain, err := client.getHostAndDomainFromRef(id)
+// RebootHost reboots the host identified by request.HostID and tells how it has been rebooted
+// The reboot is detected with the reboot event of libvirt, the host is reset if force is set and timeout is reached
+func (client *Client) RebootHost(request model.HostRebootRequest) (HostPowerMethod.Enum, error) {
+	domain, err := client.getDomainFromRef(request.HostID)
 	if err != nil {
-		return fmt.Errorf(fmt.Sprintf("getHostAndDomainFromRef failed : %s", err.Error()))
+		return HostPowerMethod.NONE, fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
 	}
 
-	err = domain.Reboot(0)
+	if request.Mode != HostRebootMode.RESET && (request.Timeout != 0 || !request.Force) {
+		flags := libvirt.DOMAIN_REBOOT_ACPI_POWER_BTN
+		if request.Mode == HostRebootMode.AGENT {
+			flags = libvirt.DOMAIN_REBOOT_GUEST_AGENT
+		}
+
+		rebooted := make(chan bool, 1)
+		if request.Timeout > 0 {
+			callbackID, err := client.LibvirtService.DomainEventRebootRegister(domain, func(c *libvirt.Connect, d *libvirt.Domain) {
+				select {
+				case rebooted <- true:
+				default:
+				}
+			})
+			if err != nil {
+				return HostPowerMethod.NONE, fmt.Errorf("Failed to register to the reboot events of the host : %s", err.Error())
+			}
+			defer client.LibvirtService.DomainEventDeregister(callbackID)
+		}
+
+		err = domain.Reboot(flags)
+		if err == nil {
+			if request.Timeout == 0 {
+				return HostPowerMethod.GRACEFUL, nil
+			}
+			select {
+			case <-rebooted:
+				return HostPowerMethod.GRACEFUL, nil
+			case <-time.After(request.Timeout):
+				err = fmt.Errorf("no reboot event received")
+			}
+		}
+		if !request.Force {
+			return HostPowerMethod.NONE, fmt.Errorf("Failed to reboot the host within %s : %s", request.Timeout, err.Error())
+		}
+	}
+
+	err = domain.Reset(0)
 	if err != nil {
-		return fmt.Errorf(fmt.Sprintf("Failed to reboot the host : %s", err.Error()))
+		return HostPowerMethod.NONE, fmt.Errorf(fmt.Sprintf("Failed to reset the host : %s", err.Error()))
 	}
+	return HostPowerMethod.FORCED, nil
+}
 
-	//TODO wait domain to be fully operational?
+// PauseHost suspends the execution of the host identified by id, its memory being kept
+func (client *Client) PauseHost(id string) error {
+	domain, err := client.getDomainFromRef(id)
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+
+	err = domain.Suspend()
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("Failed to pause the host : %s", err.Error()))
+	}
+
+	return nil
+}
+
+// ResumeHost resumes the execution of the host identified by id, paused by PauseHost
+func (client *Client) ResumeHost(id string) error {
+	domain, err := client.getDomainFromRef(id)
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+
+	err = domain.Resume()
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("Failed to resume the host : %s", err.Error()))
+	}
+
+	return nil
+}
+
+// HibernateHost saves the state of the host identified by id on disk (libvirt managed save) and stops it
+// the returned host records the saved-state file and the date of the save
+func (client *Client) HibernateHost(id string) (*model.Host, error) {
+	domain, err := client.getDomainFromRef(id)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+
+	err = domain.ManagedSave(0)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed to hibernate the host : %s", err.Error()))
+	}
+	savedAt := time.Now()
+
+	host, err := client.getHostFromDomain(domain, false)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed to get host from domain : %s", err.Error()))
+	}
+	hostHibernationV1 := propsv1.NewHostHibernation()
+	host.Properties.Get(HostProperty.HibernationV1, hostHibernationV1)
+	hostHibernationV1.SavedAt = savedAt
+	host.Properties.Set(HostProperty.HibernationV1, hostHibernationV1)
+
+	return host, nil
+}
+
+// RestoreHost starts the host identified by id from the state saved by HibernateHost
+func (client *Client) RestoreHost(id string) error {
+	domain, err := client.getDomainFromRef(id)
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+
+	hasSavedState, err := domain.HasManagedSaveImage(0)
+	if err != nil {
+		return fmt.Errorf("Failed to know if the host has a saved state : %s", err.Error())
+	}
+	if !hasSavedState {
+		return fmt.Errorf("The host %s has not been hibernated", id)
+	}
+
+	// Starting a domain having a managed save image restores it
+	err = domain.Create()
+	if err != nil {
+		return fmt.Errorf(fmt.Sprintf("Failed to restore the host : %s", err.Error()))
+	}
 
 	return nil
 }
 
-// GetHostState returns the host identified by id
-func (client *Client) GetHostState(hostParam interface{}) (HostState.Enum, error) {
-	host, err := client.GetHost(hostParam)
+// managedSaveDir returns the directory where the libvirt daemon of the connection writes the managed save images,
+// the one of the system daemon or the one of the session daemon of the user
+func (client *Client) managedSaveDir() (string, error) {
+	uri, err := client.LibvirtService.GetURI()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get the URI of the libvirt connection : %s", err.Error())
+	}
+	if strings.HasSuffix(uri, "/session") {
+		return fmt.Sprintf("%s/.config/libvirt/qemu/save", os.Getenv("HOME")), nil
+	}
+	return "/var/lib/libvirt/qemu/save", nil
+}
+
+// getHibernationV1FromDomain tells which file holds the state of the domain saved on disk, cached keeps the date of the save
+func (client *Client) getHibernationV1FromDomain(domain *libvirt.Domain, cached *propsv1.HostHibernation) (*propsv1.HostHibernation, error) {
+	hostHibernation := propsv1.NewHostHibernation()
+
+	hasSavedState, err := domain.HasManagedSaveImage(0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to know if the domain has a saved state : %s", err.Error())
+	}
+	if !hasSavedState {
+		return hostHibernation, nil
+	}
+
+	name, err := domain.GetName()
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed to fetch name from domain : %s", err.Error()))
+	}
+	saveDir, err := client.managedSaveDir()
+	if err != nil {
+		return nil, err
+	}
+	hostHibernation.StateFile = fmt.Sprintf("%s/%s.save", saveDir, name)
+	// The date of the save is only known when the host has been hibernated by HibernateHost
+	if cached.StateFile != "" {
+		hostHibernation.SavedAt = cached.SavedAt
+	}
+
+	return hostHibernation, nil
+}
+
+// getRootVolumeFromDomain returns the description of the volume holding the system of the domain
+func getRootVolumeFromDomain(domain *libvirt.Domain, libvirtService *libvirt.Connect) (*libvirtxml.StorageVolume, error) {
+	domainName, err := domain.GetName()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get domain name : %s", err.Error())
+	}
+	volumes, err := getVolumesFromDomain(domain, libvirtService)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed to get the volumes from the domain : %s", err.Error()))
+	}
+	for _, volume := range volumes {
+		pathSplitted := strings.Split(volume.Key, "/")
+		if strings.Split(pathSplitted[len(pathSplitted)-1], ".")[0] == domainName {
+			return volume, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No root volume found for domain %s", domainName)
+}
+
+// waitDomainState waits until the domain reaches the libvirt state
+func waitDomainState(domain *libvirt.Domain, state libvirt.DomainState, timeout time.Duration) error {
+	return retry.WhileUnsuccessfulDelay1Second(
+		func() error {
+			current, _, err := domain.GetState()
+			if err != nil {
+				return fmt.Errorf("Failed to get the state of the domain : %s", err.Error())
+			}
+			if current != state {
+				return fmt.Errorf("Domain is in state %d, waiting for state %d", current, state)
+			}
+			return nil
+		},
+		timeout,
+	)
+}
+
+// growRootDiskScript grows the root disk image %[1]s (format %[2]s) to %[3]d GB and expands its root filesystem,
+// the root partition is found by inspecting the image, for LVM the physical volume and the root logical volume are expanded
+const growRootDiskScript = `set -e
+VM_IMAGE="%[1]s"
+ROOT=$(guestfish --ro -a "$VM_IMAGE" run : inspect-os | head -n 1)
+[ -n "$ROOT" ] || { echo "No root filesystem found in $VM_IMAGE" >&2; exit 1; }
+case "$ROOT" in
+/dev/sd*|/dev/vd*) EXPAND="--expand $ROOT";;
+*) EXPAND="--expand $(guestfish --ro -a "$VM_IMAGE" run : pvs | head -n 1) --LV-expand $ROOT";;
+esac
+qemu-img create -f %[2]s "$VM_IMAGE.resized" %[3]dG
+virt-resize $EXPAND "$VM_IMAGE" "$VM_IMAGE.resized"
+mv "$VM_IMAGE.resized" "$VM_IMAGE"
+`
+
+// ResizeHost resizes the host identified by id to fit the template identified by templateID
+// vCPUs and memory are hot-added when the running domain allows it, otherwise the domain is stopped, redefined,
+// its root disk is grown and the domain is restarted
+func (client *Client) ResizeHost(id string, templateID string) (*model.Host, error) {
+	domain, err := client.getDomainFromRef(id)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+	template, err := client.GetTemplate(templateID)
+	if err != nil {
+		return nil, fmt.Errorf("GetTemplate failed : %s", err.Error())
+	}
+
+	rootVolume, err := getRootVolumeFromDomain(domain, client.LibvirtService)
+	if err != nil {
+		return nil, err
+	}
+	currentDiskSize := int(rootVolume.Capacity.Value / 1024 / 1024 / 1024)
+	if template.DiskSize != 0 && template.DiskSize < currentDiskSize {
+		return nil, fmt.Errorf("Template %s has a disk of %d GB, the host disk (%d GB) can't be shrunk", template.Name, template.DiskSize, currentDiskSize)
+	}
+	growDisk := template.DiskSize > currentDiskSize
+
+	info, err := domain.GetInfo()
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed to get infos from the domain : %s", err.Error()))
+	}
+	active, err := domain.IsActive()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to know if the domain is active : %s", err.Error())
+	}
+	maxVcpus, err := domain.GetMaxVcpus()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the maximal number of vCPUs of the domain : %s", err.Error())
+	}
+	memory := uint64(template.RAMSize * 1024 * 1024)
+
+	hotplug := active && !growDisk &&
+		uint(template.Cores) >= uint(info.NrVirtCpu) && uint(template.Cores) <= maxVcpus &&
+		memory >= info.Memory && memory <= info.MaxMem
+
+	if hotplug {
+		err = domain.SetVcpusFlags(uint(template.Cores), libvirt.DOMAIN_VCPU_LIVE|libvirt.DOMAIN_VCPU_CONFIG)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to hot-add vCPUs : %s", err.Error())
+		}
+		err = domain.SetMemoryFlags(memory, libvirt.DOMAIN_MEM_LIVE|libvirt.DOMAIN_MEM_CONFIG)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to hot-add memory : %s", err.Error())
+		}
+	} else {
+		if active {
+			_, err = stopDomain(domain, 5*time.Minute, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		domainXML, err := domain.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+		if err != nil {
+			return nil, fmt.Errorf(fmt.Sprintf("Failed get xml description of a domain : %s", err.Error()))
+		}
+		domainDescription := &libvirtxml.Domain{}
+		err = xml.Unmarshal([]byte(domainXML), domainDescription)
+		if err != nil {
+			return nil, fmt.Errorf(fmt.Sprintf("Failed unmarshall the domain description : %s", err.Error()))
+		}
+		domainDescription.VCPU = &libvirtxml.DomainVCPU{
+			Placement: "static",
+			Current:   strconv.Itoa(template.Cores),
+			Value:     template.Cores * hotplugFactor,
+		}
+		domainDescription.Memory = &libvirtxml.DomainMemory{
+			Value: uint(memory) * uint(hotplugFactor),
+			Unit:  "KiB",
+		}
+		domainDescription.CurrentMemory = &libvirtxml.DomainCurrentMemory{
+			Value: uint(memory),
+			Unit:  "KiB",
+		}
+		domainXML, err = domainDescription.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to marshall the domain description : %s", err.Error())
+		}
+		_, err = client.LibvirtService.DomainDefineXML(domainXML)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to redefine the domain : %s", err.Error())
+		}
+
+		if growDisk {
+			format := "raw"
+			if rootVolume.Target != nil && rootVolume.Target.Format != nil && rootVolume.Target.Format.Type != "" {
+				format = rootVolume.Target.Format.Type
+			}
+			command := fmt.Sprintf(growRootDiskScript, rootVolume.Key, format, template.DiskSize)
+			cmd := exec.Command("bash", "-c", command)
+			cmdOutput := &bytes.Buffer{}
+			cmdError := &bytes.Buffer{}
+			cmd.Stdout = cmdOutput
+			cmd.Stderr = cmdError
+			err = cmd.Run()
+			if err != nil {
+				return nil, fmt.Errorf("Failed to grow the root disk : %s : %s", err.Error(), strings.TrimSpace(cmdError.String()))
+			}
+		}
+
+		if active {
+			err = domain.Create()
+			if err != nil {
+				return nil, fmt.Errorf(fmt.Sprintf("Failed to launch the host : %s", err.Error()))
+			}
+		}
+	}
+
+	host, err := client.getHostFromDomain(domain, false)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed to get host from domain : %s", err.Error()))
+	}
+
+	hostSizingV1 := propsv1.NewHostSizing()
+	host.Properties.Get(HostProperty.SizingV1, hostSizingV1)
+	hostSizingV1.Template = template.ID
+	hostSizingV1.RequestedSize.RAMSize = float32(template.RAMSize * 1024)
+	hostSizingV1.RequestedSize.Cores = template.Cores
+	hostSizingV1.RequestedSize.DiskSize = template.DiskSize
+	hostSizingV1.RequestedSize.GPUNumber = template.GPUNumber
+	hostSizingV1.RequestedSize.GPUType = template.GPUType
+	host.Properties.Set(HostProperty.SizingV1, hostSizingV1)
+
+	return host, nil
+}
+
+// GetHostState returns the state of the host identified by id, the description of the state and its reason and the
+// libvirt reason code
+func (client *Client) GetHostState(hostParam interface{}) (HostState.Enum, string, int, error) {
+	domain, err := client.getDomainFromRef(hostRefFromParam(hostParam))
+	if err != nil {
+		return HostState.ERROR, "", 0, err
+	}
+	state, reason, err := domain.GetState()
 	if err != nil {
-		return HostState.ERROR, err
+		return HostState.ERROR, "", 0, fmt.Errorf(fmt.Sprintf("Failed to fetch state from domain : %s", err.Error()))
 	}
-	return host.LastState, nil
+	hostState, stateReason := stateConvert(state, reason)
+	return hostState, stateReason, reason, nil
 }
 
 //-------------Provider Infos-------------------------------------------------------------------------------------------
@@ -0,0 +1,94 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	libvirt "github.com/libvirt/libvirt-go"
+)
+
+// consoleStream wraps a libvirt stream connected to the console of a domain as an io.ReadWriteCloser
+type consoleStream struct {
+	stream *libvirt.Stream
+	ended  int32 // set to 1 once the console has closed the stream (EOF)
+}
+
+// Read receives data from the console
+func (cs *consoleStream) Read(p []byte) (int, error) {
+	n, err := cs.stream.Recv(p)
+	if err != nil {
+		return n, err
+	}
+	if n == 0 {
+		atomic.StoreInt32(&cs.ended, 1)
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// Write sends data to the console
+func (cs *consoleStream) Write(p []byte) (int, error) {
+	return cs.stream.Send(p)
+}
+
+// Close terminates and releases the stream, a stream still open on the console side (the session ended by
+// the escape sequence while a Read is pending) is aborted instead of being finished
+func (cs *consoleStream) Close() error {
+	var err error
+	if atomic.LoadInt32(&cs.ended) == 1 {
+		err = cs.stream.Finish()
+		if err != nil {
+			cs.stream.Abort()
+		}
+	} else {
+		err = cs.stream.Abort()
+	}
+	cs.stream.Free()
+	return err
+}
+
+// OpenHostConsole opens a stream on the serial console of the host identified by id
+func (client *Client) OpenHostConsole(id string) (io.ReadWriteCloser, error) {
+	domain, err := client.getDomainFromRef(id)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+
+	active, err := domain.IsActive()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to know if the domain is active : %s", err.Error())
+	}
+	if !active {
+		return nil, fmt.Errorf("The host %s is not running", id)
+	}
+
+	stream, err := client.LibvirtService.NewStream(0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create a libvirt stream : %s", err.Error())
+	}
+	// An empty devname opens the first console (the serial one defined by CreateHost)
+	err = domain.OpenConsole("", stream, libvirt.DOMAIN_CONSOLE_SAFE)
+	if err != nil {
+		stream.Free()
+		return nil, fmt.Errorf("Failed to open the console of the host (hosts created before serial consoles were defined have none) : %s", err.Error())
+	}
+
+	return &consoleStream{stream: stream}, nil
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strings"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+)
+
+// generateMAC returns a random MAC address in the range used by qemu (52:54:00:xx:xx:xx)
+func generateMAC() (string, error) {
+	buf := make([]byte, 3)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate a MAC address : %s", err.Error())
+	}
+	return fmt.Sprintf("52:54:00:%02x:%02x:%02x", buf[0], buf[1], buf[2]), nil
+}
+
+// checkAddressAvailable checks that ip is an IPv4 address of libvirtNetwork, neither reserved nor leased
+func checkAddressAvailable(libvirtNetwork *libvirt.Network, ip string) error {
+	address := net.ParseIP(ip)
+	if address == nil || address.To4() == nil {
+		return fmt.Errorf("%s is not an IPv4 address, only IPv4 addresses can be reserved", ip)
+	}
+
+	ipam, err := getNetworkIPAM(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+	return checkAddress(ipam, ip)
+}
+
+// dhcpHostXML returns the xml of the DHCP reservation of ip for mac
+func dhcpHostXML(mac string, ip string) string {
+	return `<host mac="` + mac + `" ip="` + ip + `"/>`
+}
+
+// reserveAddress reserves ip in the DHCP of libvirtNetwork for the interface whose MAC address is mac
+func reserveAddress(libvirtNetwork *libvirt.Network, mac string, ip string) error {
+	flags, err := networkUpdateFlags(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+	err = libvirtNetwork.Update(libvirt.NETWORK_UPDATE_COMMAND_ADD_LAST, libvirt.NETWORK_SECTION_IP_DHCP_HOST, -1, dhcpHostXML(mac, ip), flags)
+	if err != nil {
+		return fmt.Errorf("Failed to reserve %s for %s : %s", ip, mac, err.Error())
+	}
+	return nil
+}
+
+// removeReservations removes the DHCP reservations of libvirtNetwork made for the interface whose MAC address is mac
+func removeReservations(libvirtNetwork *libvirt.Network, mac string) error {
+	networkDescription, err := getNetworkDescription(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+	flags, err := networkUpdateFlags(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+
+	for _, netIP := range networkDescription.IPs {
+		if netIP.DHCP == nil {
+			continue
+		}
+		for _, host := range netIP.DHCP.Hosts {
+			if !strings.EqualFold(host.MAC, mac) {
+				continue
+			}
+			err = libvirtNetwork.Update(libvirt.NETWORK_UPDATE_COMMAND_DELETE, libvirt.NETWORK_SECTION_IP_DHCP_HOST, -1, dhcpHostXML(host.MAC, host.IP), flags)
+			if err != nil {
+				return fmt.Errorf("Failed to remove the reservation of %s for %s : %s", host.IP, host.MAC, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// removeDomainReservations removes the DHCP reservations made for the interfaces of the described domain
+func (client *Client) removeDomainReservations(domainDescription *libvirtxml.Domain) error {
+	for _, iface := range domainDescription.Devices.Interfaces {
+		if iface.MAC == nil || iface.Source == nil || iface.Source.Network == nil {
+			continue
+		}
+		libvirtNetwork, err := getNetworkFromRef(iface.Source.Network.Network, client.LibvirtService)
+		if err != nil {
+			// The network has already been deleted, with its reservations
+			continue
+		}
+		err = removeReservations(libvirtNetwork, iface.MAC.Address)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
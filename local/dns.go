@@ -0,0 +1,192 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	propsv2 "github.com/CS-SI/LocalDriver/model/properties/v2"
+	libvirt "github.com/libvirt/libvirt-go"
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+)
+
+// networkUpdateFlags returns the flags applying a NetworkUpdate to the running network and to its persistent definition
+func networkUpdateFlags(libvirtNetwork *libvirt.Network) (libvirt.NetworkUpdateFlags, error) {
+	var flags libvirt.NetworkUpdateFlags
+
+	active, err := libvirtNetwork.IsActive()
+	if err != nil {
+		return flags, fmt.Errorf("Failed to get network state : %s", err.Error())
+	}
+	persistent, err := libvirtNetwork.IsPersistent()
+	if err != nil {
+		return flags, fmt.Errorf("Failed to know if the network is persistent : %s", err.Error())
+	}
+
+	if active {
+		flags |= libvirt.NETWORK_UPDATE_AFFECT_LIVE
+	}
+	if persistent {
+		flags |= libvirt.NETWORK_UPDATE_AFFECT_CONFIG
+	}
+	return flags, nil
+}
+
+// getNetworkNamesFromDomain returns the names of the libvirt networks the interfaces of the described domain are plugged on
+func getNetworkNamesFromDomain(domainDescription *libvirtxml.Domain) []string {
+	names := []string{}
+	for _, iface := range domainDescription.Devices.Interfaces {
+		if iface.Source != nil && iface.Source.Network != nil {
+			names = append(names, iface.Source.Network.Network)
+		}
+	}
+	return names
+}
+
+// dnsHostXML returns the xml of the DNS record resolving hostnames into ip
+func dnsHostXML(ip string, hostnames []string) string {
+	hostnamesXML := ""
+	for _, hostname := range hostnames {
+		hostnamesXML += "<hostname>" + hostname + "</hostname>"
+	}
+	return `<host ip="` + ip + `">` + hostnamesXML + `</host>`
+}
+
+// removeDNSHostRecords removes from the DNS of libvirtNetwork the records whose ip or one of the hostnames is in keys
+func removeDNSHostRecords(libvirtNetwork *libvirt.Network, keys []string) error {
+	networkDescription, err := getNetworkDescription(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+	if networkDescription.DNS == nil {
+		return nil
+	}
+	flags, err := networkUpdateFlags(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range networkDescription.DNS.Host {
+		hostnames := []string{}
+		for _, hostname := range record.Hostnames {
+			hostnames = append(hostnames, hostname.Hostname)
+		}
+		matches := false
+		for _, key := range keys {
+			if key == record.IP || contains(hostnames, key) {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		err = libvirtNetwork.Update(libvirt.NETWORK_UPDATE_COMMAND_DELETE, libvirt.NETWORK_SECTION_DNS_HOST, -1, dnsHostXML(record.IP, hostnames), flags)
+		if err != nil {
+			return fmt.Errorf("Failed to remove the DNS record of %s : %s", strings.Join(hostnames, ", "), err.Error())
+		}
+	}
+	return nil
+}
+
+// addHostDNSRecords registers hostnames in the DNS of every network host has an address on
+// libvirt refuses two records sharing a hostname, so a dual-stack host is only registered with its IPv4 address
+func (client *Client) addHostDNSRecords(host *model.Host, hostnames []string) error {
+	hostNetworkV2 := propsv2.NewHostNetwork()
+	err := host.Properties.Get(HostProperty.NetworkV2, hostNetworkV2)
+	if err != nil {
+		return err
+	}
+
+	for networkID := range hostNetworkV2.NetworksByID {
+		ip, ok := hostNetworkV2.IPv4Addresses[networkID]
+		if !ok {
+			ip, ok = hostNetworkV2.IPv6Addresses[networkID]
+			if !ok {
+				continue
+			}
+		}
+
+		libvirtNetwork, err := getNetworkFromRef(networkID, client.LibvirtService)
+		if err != nil {
+			return err
+		}
+		err = addDNSHostRecord(libvirtNetwork, ip, hostnames)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDNSHostRecord registers hostnames with the address ip in the DNS of libvirtNetwork
+func addDNSHostRecord(libvirtNetwork *libvirt.Network, ip string, hostnames []string) error {
+	networkDescription, err := getNetworkDescription(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+	// The DNS of a network plugged on an existing bridge is not managed by libvirt
+	if len(networkDescription.IPs) == 0 {
+		return nil
+	}
+
+	// Records left by a previous host with the same name or address are replaced
+	err = removeDNSHostRecords(libvirtNetwork, append([]string{ip}, hostnames...))
+	if err != nil {
+		return err
+	}
+
+	flags, err := networkUpdateFlags(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+	err = libvirtNetwork.Update(libvirt.NETWORK_UPDATE_COMMAND_ADD_LAST, libvirt.NETWORK_SECTION_DNS_HOST, -1, dnsHostXML(ip, hostnames), flags)
+	if err != nil {
+		return fmt.Errorf("Failed to add the DNS record of %s : %s", strings.Join(hostnames, ", "), err.Error())
+	}
+	return nil
+}
+
+// removeDomainDNSRecords removes hostname from the DNS of every network the described domain is plugged on
+func (client *Client) removeDomainDNSRecords(domainDescription *libvirtxml.Domain, hostname string) error {
+	for _, networkName := range getNetworkNamesFromDomain(domainDescription) {
+		libvirtNetwork, err := getNetworkFromRef(networkName, client.LibvirtService)
+		if err != nil {
+			// The network has already been deleted, with its records
+			continue
+		}
+		err = removeDNSHostRecords(libvirtNetwork, []string{hostname})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contains tells if list contains str
+func contains(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
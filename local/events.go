@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/CS-SI/LocalDriver/model"
+	libvirt "github.com/libvirt/libvirt-go"
+)
+
+// domainEventNames contains the description of the libvirt domain lifecycle events
+var domainEventNames = map[libvirt.DomainEventType]string{
+	libvirt.DOMAIN_EVENT_DEFINED:     "defined",
+	libvirt.DOMAIN_EVENT_UNDEFINED:   "undefined",
+	libvirt.DOMAIN_EVENT_STARTED:     "started",
+	libvirt.DOMAIN_EVENT_SUSPENDED:   "suspended",
+	libvirt.DOMAIN_EVENT_RESUMED:     "resumed",
+	libvirt.DOMAIN_EVENT_STOPPED:     "stopped",
+	libvirt.DOMAIN_EVENT_SHUTDOWN:    "shutdown",
+	libvirt.DOMAIN_EVENT_PMSUSPENDED: "pmsuspended",
+	libvirt.DOMAIN_EVENT_CRASHED:     "crashed",
+}
+
+// domainEventDetailNames contains the description of the detail codes of each libvirt domain lifecycle event
+var domainEventDetailNames = map[libvirt.DomainEventType]map[int]string{
+	libvirt.DOMAIN_EVENT_DEFINED: {
+		int(libvirt.DOMAIN_EVENT_DEFINED_ADDED):         "added",
+		int(libvirt.DOMAIN_EVENT_DEFINED_UPDATED):       "updated",
+		int(libvirt.DOMAIN_EVENT_DEFINED_RENAMED):       "renamed",
+		int(libvirt.DOMAIN_EVENT_DEFINED_FROM_SNAPSHOT): "from snapshot",
+	},
+	libvirt.DOMAIN_EVENT_UNDEFINED: {
+		int(libvirt.DOMAIN_EVENT_UNDEFINED_REMOVED): "removed",
+		int(libvirt.DOMAIN_EVENT_UNDEFINED_RENAMED): "renamed",
+	},
+	libvirt.DOMAIN_EVENT_STARTED: {
+		int(libvirt.DOMAIN_EVENT_STARTED_BOOTED):        "booted",
+		int(libvirt.DOMAIN_EVENT_STARTED_MIGRATED):      "migrated",
+		int(libvirt.DOMAIN_EVENT_STARTED_RESTORED):      "restored",
+		int(libvirt.DOMAIN_EVENT_STARTED_FROM_SNAPSHOT): "from snapshot",
+		int(libvirt.DOMAIN_EVENT_STARTED_WAKEUP):        "wakeup",
+	},
+	libvirt.DOMAIN_EVENT_SUSPENDED: {
+		int(libvirt.DOMAIN_EVENT_SUSPENDED_PAUSED):        "paused",
+		int(libvirt.DOMAIN_EVENT_SUSPENDED_MIGRATED):      "migrated",
+		int(libvirt.DOMAIN_EVENT_SUSPENDED_IOERROR):       "I/O error",
+		int(libvirt.DOMAIN_EVENT_SUSPENDED_WATCHDOG):      "watchdog",
+		int(libvirt.DOMAIN_EVENT_SUSPENDED_RESTORED):      "restored",
+		int(libvirt.DOMAIN_EVENT_SUSPENDED_FROM_SNAPSHOT): "from snapshot",
+		int(libvirt.DOMAIN_EVENT_SUSPENDED_API_ERROR):     "API error",
+	},
+	libvirt.DOMAIN_EVENT_RESUMED: {
+		int(libvirt.DOMAIN_EVENT_RESUMED_UNPAUSED):      "unpaused",
+		int(libvirt.DOMAIN_EVENT_RESUMED_MIGRATED):      "migrated",
+		int(libvirt.DOMAIN_EVENT_RESUMED_FROM_SNAPSHOT): "from snapshot",
+	},
+	libvirt.DOMAIN_EVENT_STOPPED: {
+		int(libvirt.DOMAIN_EVENT_STOPPED_SHUTDOWN):      "shutdown",
+		int(libvirt.DOMAIN_EVENT_STOPPED_DESTROYED):     "destroyed",
+		int(libvirt.DOMAIN_EVENT_STOPPED_CRASHED):       "crashed",
+		int(libvirt.DOMAIN_EVENT_STOPPED_MIGRATED):      "migrated",
+		int(libvirt.DOMAIN_EVENT_STOPPED_SAVED):         "saved",
+		int(libvirt.DOMAIN_EVENT_STOPPED_FAILED):        "failed",
+		int(libvirt.DOMAIN_EVENT_STOPPED_FROM_SNAPSHOT): "from snapshot",
+	},
+	libvirt.DOMAIN_EVENT_SHUTDOWN: {
+		int(libvirt.DOMAIN_EVENT_SHUTDOWN_FINISHED): "finished",
+	},
+	libvirt.DOMAIN_EVENT_PMSUSPENDED: {
+		int(libvirt.DOMAIN_EVENT_PMSUSPENDED_MEMORY): "memory",
+		int(libvirt.DOMAIN_EVENT_PMSUSPENDED_DISK):   "disk",
+	},
+	libvirt.DOMAIN_EVENT_CRASHED: {
+		int(libvirt.DOMAIN_EVENT_CRASHED_PANICKED): "panicked",
+	},
+}
+
+// networkEventNames contains the description of the libvirt network lifecycle events
+var networkEventNames = map[libvirt.NetworkEventLifecycleType]string{
+	libvirt.NETWORK_EVENT_DEFINED:   "defined",
+	libvirt.NETWORK_EVENT_UNDEFINED: "undefined",
+	libvirt.NETWORK_EVENT_STARTED:   "started",
+	libvirt.NETWORK_EVENT_STOPPED:   "stopped",
+}
+
+// storagePoolEventNames contains the description of the libvirt storage pool lifecycle events
+var storagePoolEventNames = map[libvirt.StoragePoolEventLifecycleType]string{
+	libvirt.STORAGE_POOL_EVENT_DEFINED:   "defined",
+	libvirt.STORAGE_POOL_EVENT_UNDEFINED: "undefined",
+	libvirt.STORAGE_POOL_EVENT_STARTED:   "started",
+	libvirt.STORAGE_POOL_EVENT_STOPPED:   "stopped",
+	libvirt.STORAGE_POOL_EVENT_CREATED:   "created",
+	libvirt.STORAGE_POOL_EVENT_DELETED:   "deleted",
+}
+
+// eventName returns the description of an event, or its code if unknown
+func eventName(name string, ok bool, code int) string {
+	if !ok {
+		return strconv.Itoa(code)
+	}
+	return name
+}
+
+// newHostEvent builds the event of a domain, with the state of the domain after the event
+func newHostEvent(domain *libvirt.Domain, event string, detail string) *model.Event {
+	hostEvent := &model.Event{
+		Time:         time.Now(),
+		ResourceType: model.EventHost,
+		Event:        event,
+		Detail:       detail,
+	}
+	hostEvent.ResourceID, _ = domain.GetUUIDString()
+	hostEvent.ResourceName, _ = domain.GetName()
+	// The state can't be fetched anymore once the domain is undefined
+	if state, reason, err := domain.GetState(); err == nil {
+		hostEvent.HostState, hostEvent.StateReason = stateConvert(state, reason)
+		hostEvent.StateReasonCode = reason
+		hostEvent.StateName = hostEvent.HostState.String()
+	}
+	return hostEvent
+}
+
+// SubscribeEvents calls callback for each lifecycle event of the domains, networks and storage pools, until unsubscribe is called
+// Callbacks are called from the libvirt event loop, they must not block
+func (client *Client) SubscribeEvents(callback func(event *model.Event)) (unsubscribe func(), err error) {
+	var domainCallbackIDs, networkCallbackIDs, storagePoolCallbackIDs []int
+	unsubscribe = func() {
+		for _, id := range domainCallbackIDs {
+			client.LibvirtService.DomainEventDeregister(id)
+		}
+		for _, id := range networkCallbackIDs {
+			client.LibvirtService.NetworkEventDeregister(id)
+		}
+		for _, id := range storagePoolCallbackIDs {
+			client.LibvirtService.StoragePoolEventDeregister(id)
+		}
+	}
+	defer func() {
+		if err != nil {
+			unsubscribe()
+		}
+	}()
+
+	id, err := client.LibvirtService.DomainEventLifecycleRegister(nil, func(c *libvirt.Connect, d *libvirt.Domain, event *libvirt.DomainEventLifecycle) {
+		name, ok := domainEventNames[event.Event]
+		detail, detailOk := domainEventDetailNames[event.Event][event.Detail]
+		callback(newHostEvent(d, eventName(name, ok, int(event.Event)), eventName(detail, detailOk, event.Detail)))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to register to the domain lifecycle events : %s", err.Error())
+	}
+	domainCallbackIDs = append(domainCallbackIDs, id)
+
+	id, err = client.LibvirtService.DomainEventRebootRegister(nil, func(c *libvirt.Connect, d *libvirt.Domain) {
+		callback(newHostEvent(d, "rebooted", ""))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to register to the domain reboot events : %s", err.Error())
+	}
+	domainCallbackIDs = append(domainCallbackIDs, id)
+
+	id, err = client.LibvirtService.NetworkEventLifecycleRegister(nil, func(c *libvirt.Connect, n *libvirt.Network, event *libvirt.NetworkEventLifecycle) {
+		name, ok := networkEventNames[event.Event]
+		networkEvent := &model.Event{
+			Time:         time.Now(),
+			ResourceType: model.EventNetwork,
+			Event:        eventName(name, ok, int(event.Event)),
+		}
+		networkEvent.ResourceID, _ = n.GetUUIDString()
+		networkEvent.ResourceName, _ = n.GetName()
+		callback(networkEvent)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to register to the network lifecycle events : %s", err.Error())
+	}
+	networkCallbackIDs = append(networkCallbackIDs, id)
+
+	id, err = client.LibvirtService.StoragePoolEventLifecycleRegister(nil, func(c *libvirt.Connect, p *libvirt.StoragePool, event *libvirt.StoragePoolEventLifecycle) {
+		name, ok := storagePoolEventNames[event.Event]
+		storagePoolEvent := &model.Event{
+			Time:         time.Now(),
+			ResourceType: model.EventStoragePool,
+			Event:        eventName(name, ok, int(event.Event)),
+		}
+		storagePoolEvent.ResourceID, _ = p.GetUUIDString()
+		storagePoolEvent.ResourceName, _ = p.GetName()
+		callback(storagePoolEvent)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to register to the storage pool lifecycle events : %s", err.Error())
+	}
+	storagePoolCallbackIDs = append(storagePoolCallbackIDs, id)
+
+	return unsubscribe, nil
+}
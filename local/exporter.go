@@ -0,0 +1,264 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"sync"
+
+	"github.com/CS-SI/LocalDriver/metadata"
+	"github.com/CS-SI/LocalDriver/model"
+	libvirt "github.com/libvirt/libvirt-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "virt"
+
+var (
+	hostStateDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "state"),
+		"State of the host (1 for the current state).", []string{"host", "state"}, nil)
+	hostVCPUsDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "vcpus"),
+		"Number of vCPUs of the host.", []string{"host"}, nil)
+	hostCPUSecondsDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "cpu_seconds_total"),
+		"CPU time consumed by the host.", []string{"host"}, nil)
+	hostMemoryBalloonDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "memory_balloon_bytes"),
+		"Memory currently given to the host.", []string{"host"}, nil)
+	hostMemoryMaximumDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "memory_maximum_bytes"),
+		"Maximal memory of the host.", []string{"host"}, nil)
+	hostMemoryRSSDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "memory_rss_bytes"),
+		"Memory used by the host on the hypervisor.", []string{"host"}, nil)
+	hostDiskReadBytesDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "disk_read_bytes_total"),
+		"Bytes read from the disk of the host.", []string{"host", "disk"}, nil)
+	hostDiskReadOpsDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "disk_read_ops_total"),
+		"Read operations on the disk of the host.", []string{"host", "disk"}, nil)
+	hostDiskWriteBytesDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "disk_write_bytes_total"),
+		"Bytes written to the disk of the host.", []string{"host", "disk"}, nil)
+	hostDiskWriteOpsDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "disk_write_ops_total"),
+		"Write operations on the disk of the host.", []string{"host", "disk"}, nil)
+	hostNetRxBytesDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "network_receive_bytes_total"),
+		"Bytes received by the interface of the host.", []string{"host", "interface"}, nil)
+	hostNetRxPacketsDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "network_receive_packets_total"),
+		"Packets received by the interface of the host.", []string{"host", "interface"}, nil)
+	hostNetTxBytesDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "network_transmit_bytes_total"),
+		"Bytes transmitted by the interface of the host.", []string{"host", "interface"}, nil)
+	hostNetTxPacketsDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "host", "network_transmit_packets_total"),
+		"Packets transmitted by the interface of the host.", []string{"host", "interface"}, nil)
+	poolCapacityDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "storage_pool", "capacity_bytes"),
+		"Capacity of the storage pool.", []string{"pool"}, nil)
+	poolAllocationDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "storage_pool", "allocation_bytes"),
+		"Allocation of the storage pool.", []string{"pool"}, nil)
+	poolAvailableDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "storage_pool", "available_bytes"),
+		"Free space of the storage pool.", []string{"pool"}, nil)
+	networkLeasesDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "network", "dhcp_leases"),
+		"Number of DHCP leases of the network.", []string{"network"}, nil)
+	driftDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "metadata", "drift"),
+		"Number of resources whose metadata and libvirt definition disagree.", []string{"resource", "kind"}, nil)
+)
+
+// Collector is a prometheus.Collector exposing the state of the hypervisor and of its hosts
+// Scrapes are serialized, so concurrent scrapes don't multiply the load on libvirt
+type Collector struct {
+	client *Client
+	mutex  sync.Mutex
+}
+
+// NewCollector creates a Collector using the connections of client
+func NewCollector(client *Client) *Collector {
+	return &Collector{client: client}
+}
+
+// Describe implements prometheus.Collector
+func (collector *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		hostStateDesc, hostVCPUsDesc, hostCPUSecondsDesc, hostMemoryBalloonDesc, hostMemoryMaximumDesc, hostMemoryRSSDesc,
+		hostDiskReadBytesDesc, hostDiskReadOpsDesc, hostDiskWriteBytesDesc, hostDiskWriteOpsDesc,
+		hostNetRxBytesDesc, hostNetRxPacketsDesc, hostNetTxBytesDesc, hostNetTxPacketsDesc,
+		poolCapacityDesc, poolAllocationDesc, poolAvailableDesc, networkLeasesDesc, driftDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (collector *Collector) Collect(ch chan<- prometheus.Metric) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+
+	collector.collectHosts(ch)
+	collector.collectStoragePools(ch)
+	collector.collectNetworks(ch)
+	collector.collectDrift(ch)
+}
+
+func (collector *Collector) collectHosts(ch chan<- prometheus.Metric) {
+	domains, err := collector.client.LibvirtService.ListAllDomains(libvirt.CONNECT_LIST_DOMAINS_ACTIVE | libvirt.CONNECT_LIST_DOMAINS_INACTIVE)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(hostStateDesc, err)
+		return
+	}
+	for _, domain := range domains {
+		name, nameErr := domain.GetName()
+		state, reason, stateErr := domain.GetState()
+		domain.Free()
+		if nameErr != nil || stateErr != nil {
+			continue
+		}
+		hostState, _ := stateConvert(state, reason)
+		ch <- prometheus.MustNewConstMetric(hostStateDesc, prometheus.GaugeValue, 1, name, hostState.String())
+	}
+
+	hostsStats, err := collector.client.getDomainsStats(nil)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(hostCPUSecondsDesc, err)
+		return
+	}
+	for _, stats := range hostsStats {
+		ch <- prometheus.MustNewConstMetric(hostVCPUsDesc, prometheus.GaugeValue, float64(stats.VCPUs), stats.HostName)
+		ch <- prometheus.MustNewConstMetric(hostCPUSecondsDesc, prometheus.CounterValue, float64(stats.CPUTime)/1e9, stats.HostName)
+		ch <- prometheus.MustNewConstMetric(hostMemoryBalloonDesc, prometheus.GaugeValue, float64(stats.MemoryBalloon*1024), stats.HostName)
+		ch <- prometheus.MustNewConstMetric(hostMemoryMaximumDesc, prometheus.GaugeValue, float64(stats.MemoryMaximum*1024), stats.HostName)
+		ch <- prometheus.MustNewConstMetric(hostMemoryRSSDesc, prometheus.GaugeValue, float64(stats.MemoryRSS*1024), stats.HostName)
+		for _, disk := range stats.Disks {
+			ch <- prometheus.MustNewConstMetric(hostDiskReadBytesDesc, prometheus.CounterValue, float64(disk.ReadBytes), stats.HostName, disk.Name)
+			ch <- prometheus.MustNewConstMetric(hostDiskReadOpsDesc, prometheus.CounterValue, float64(disk.ReadOps), stats.HostName, disk.Name)
+			ch <- prometheus.MustNewConstMetric(hostDiskWriteBytesDesc, prometheus.CounterValue, float64(disk.WriteBytes), stats.HostName, disk.Name)
+			ch <- prometheus.MustNewConstMetric(hostDiskWriteOpsDesc, prometheus.CounterValue, float64(disk.WriteOps), stats.HostName, disk.Name)
+		}
+		for _, iface := range stats.Interfaces {
+			ch <- prometheus.MustNewConstMetric(hostNetRxBytesDesc, prometheus.CounterValue, float64(iface.RxBytes), stats.HostName, iface.Name)
+			ch <- prometheus.MustNewConstMetric(hostNetRxPacketsDesc, prometheus.CounterValue, float64(iface.RxPackets), stats.HostName, iface.Name)
+			ch <- prometheus.MustNewConstMetric(hostNetTxBytesDesc, prometheus.CounterValue, float64(iface.TxBytes), stats.HostName, iface.Name)
+			ch <- prometheus.MustNewConstMetric(hostNetTxPacketsDesc, prometheus.CounterValue, float64(iface.TxPackets), stats.HostName, iface.Name)
+		}
+	}
+}
+
+func (collector *Collector) collectStoragePools(ch chan<- prometheus.Metric) {
+	pools, err := collector.client.LibvirtService.ListAllStoragePools(libvirt.CONNECT_LIST_STORAGE_POOLS_ACTIVE)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(poolCapacityDesc, err)
+		return
+	}
+	for _, pool := range pools {
+		name, nameErr := pool.GetName()
+		info, infoErr := pool.GetInfo()
+		pool.Free()
+		if nameErr != nil || infoErr != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(poolCapacityDesc, prometheus.GaugeValue, float64(info.Capacity), name)
+		ch <- prometheus.MustNewConstMetric(poolAllocationDesc, prometheus.GaugeValue, float64(info.Allocation), name)
+		ch <- prometheus.MustNewConstMetric(poolAvailableDesc, prometheus.GaugeValue, float64(info.Available), name)
+	}
+}
+
+func (collector *Collector) collectNetworks(ch chan<- prometheus.Metric) {
+	networks, err := collector.client.LibvirtService.ListAllNetworks(libvirt.CONNECT_LIST_NETWORKS_ACTIVE)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(networkLeasesDesc, err)
+		return
+	}
+	for _, network := range networks {
+		name, nameErr := network.GetName()
+		leases, leasesErr := network.GetDHCPLeases()
+		network.Free()
+		if nameErr != nil || leasesErr != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(networkLeasesDesc, prometheus.GaugeValue, float64(len(leases)), name)
+	}
+}
+
+// collectDrift counts the hosts and networks known only by the metadata or only by libvirt, and the hosts whose state differs
+func (collector *Collector) collectDrift(ch chan<- prometheus.Metric) {
+	domainStates := map[string]string{}
+	domains, err := collector.client.LibvirtService.ListAllDomains(libvirt.CONNECT_LIST_DOMAINS_ACTIVE | libvirt.CONNECT_LIST_DOMAINS_INACTIVE)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(driftDesc, err)
+		return
+	}
+	for _, domain := range domains {
+		id, idErr := domain.GetUUIDString()
+		state, reason, stateErr := domain.GetState()
+		domain.Free()
+		if idErr != nil || stateErr != nil {
+			continue
+		}
+		hostState, _ := stateConvert(state, reason)
+		domainStates[id] = hostState.String()
+	}
+
+	missingInLibvirt, stateMismatch := 0, 0
+	known := map[string]bool{}
+	err = metadata.NewHost(collector.client).Browse(func(host *model.Host) error {
+		known[host.ID] = true
+		state, ok := domainStates[host.ID]
+		if !ok {
+			missingInLibvirt++
+		} else if state != host.LastState.String() {
+			stateMismatch++
+		}
+		return nil
+	})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(driftDesc, err)
+		return
+	}
+	missingInMetadata := 0
+	for id := range domainStates {
+		if !known[id] {
+			missingInMetadata++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(driftDesc, prometheus.GaugeValue, float64(missingInLibvirt), "host", "missing_in_libvirt")
+	ch <- prometheus.MustNewConstMetric(driftDesc, prometheus.GaugeValue, float64(missingInMetadata), "host", "missing_in_metadata")
+	ch <- prometheus.MustNewConstMetric(driftDesc, prometheus.GaugeValue, float64(stateMismatch), "host", "state_mismatch")
+
+	networkIDs := map[string]bool{}
+	networks, err := collector.client.LibvirtService.ListAllNetworks(libvirt.CONNECT_LIST_NETWORKS_ACTIVE | libvirt.CONNECT_LIST_NETWORKS_INACTIVE)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(driftDesc, err)
+		return
+	}
+	for _, network := range networks {
+		id, err := network.GetUUIDString()
+		if err == nil {
+			networkIDs[id] = true
+		}
+		network.Free()
+	}
+	missingInLibvirt = 0
+	knownNetworks := map[string]bool{}
+	err = metadata.NewNetwork(collector.client).Browse(func(network *model.Network) error {
+		knownNetworks[network.ID] = true
+		if !networkIDs[network.ID] {
+			missingInLibvirt++
+		}
+		return nil
+	})
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(driftDesc, err)
+		return
+	}
+	missingInMetadata = 0
+	for id := range networkIDs {
+		if !knownNetworks[id] {
+			missingInMetadata++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(driftDesc, prometheus.GaugeValue, float64(missingInLibvirt), "network", "missing_in_libvirt")
+	ch <- prometheus.MustNewConstMetric(driftDesc, prometheus.GaugeValue, float64(missingInMetadata), "network", "missing_in_metadata")
+}
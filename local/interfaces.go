@@ -0,0 +1,248 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"net"
+
+	libvirt "github.com/libvirt/libvirt-go"
+
+	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	propsv2 "github.com/CS-SI/LocalDriver/model/properties/v2"
+)
+
+// setHostNetworkAttachment records in the network properties of host that it is plugged on network, or unplugged from it
+func setHostNetworkAttachment(host *model.Host, network *model.Network, attached bool) error {
+	hostNetworkV2 := propsv2.NewHostNetwork()
+	err := host.Properties.Get(HostProperty.NetworkV2, hostNetworkV2)
+	if err != nil {
+		return err
+	}
+
+	if attached {
+		hostNetworkV2.NetworksByID[network.ID] = network.Name
+		hostNetworkV2.NetworksByName[network.Name] = network.ID
+	} else {
+		delete(hostNetworkV2.NetworksByID, network.ID)
+		delete(hostNetworkV2.NetworksByName, network.Name)
+		ips := []string{hostNetworkV2.IPv4Addresses[network.ID], hostNetworkV2.IPv6Addresses[network.ID]}
+		for _, ip := range ips {
+			delete(hostNetworkV2.AddressSources, ip)
+		}
+		delete(hostNetworkV2.IPv4Addresses, network.ID)
+		delete(hostNetworkV2.IPv6Addresses, network.ID)
+	}
+
+	err = host.Properties.Set(HostProperty.NetworkV2, hostNetworkV2)
+	if err != nil {
+		return err
+	}
+	return host.Properties.Set(HostProperty.NetworkV1, hostNetworkV2.HostNetwork)
+}
+
+// getDomainAndNetworkFromRequest returns the domain and the network of request
+func (client *Client) getDomainAndNetworkFromRequest(request model.HostNetworkRequest) (*libvirt.Domain, *libvirt.Network, *model.Network, error) {
+	domain, err := client.getDomainFromRef(request.HostID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	libvirtNetwork, err := getNetworkFromRef(request.NetworkID, client.LibvirtService)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	network, err := getNetworkFromLibvirtNetwork(libvirtNetwork)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Failed to get network from libvirt network : %s", err.Error())
+	}
+	return domain, libvirtNetwork, network, nil
+}
+
+// AttachHostToNetwork plugs a new interface of the host request.HostID on the network request.NetworkID
+// Both the running host and its persistent definition get the interface, which is filtered by the security groups of the host,
+// its address is reserved in the DHCP of the network and the host is registered in the DNS of the network
+func (client *Client) AttachHostToNetwork(request model.HostNetworkRequest) (*model.Host, error) {
+	domain, libvirtNetwork, network, err := client.getDomainAndNetworkFromRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	domainDescription, err := getDomainDescription(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	filterXML := ""
+	for _, iface := range domainDescription.Devices.Interfaces {
+		if iface.Source == nil || iface.Source.Network == nil {
+			continue
+		}
+		if iface.Source.Network.Network == network.Name {
+			return nil, fmt.Errorf("Host '%s' is already plugged on network '%s'", domainDescription.Name, network.Name)
+		}
+		if iface.FilterRef != nil {
+			filterXML = `
+		<filterref filter='` + iface.FilterRef.Filter + `'/>`
+		}
+	}
+
+	// The tags of the port of the host replace the ones of the network, libvirt only tags Open vSwitch ports
+	vlansXML := ""
+	if len(request.VLANs) != 0 {
+		if network.VirtualPort != openvswitchBridge {
+			return nil, fmt.Errorf("Network '%s' is not plugged on an Open vSwitch bridge, the port of the host can't be tagged", network.Name)
+		}
+		vlansXML, err = vlanXML(request.VLANs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mac, err := generateMAC()
+	if err != nil {
+		return nil, err
+	}
+	// The IPv4 address of the interface is reserved up front to register the host in the DNS of the network,
+	// a free one is picked when none is requested (unless libvirt gives no IPv4 address on the network)
+	ip := request.IPAddress
+	if ip != "" {
+		err = checkAddressAvailable(libvirtNetwork, ip)
+		if err != nil {
+			return nil, fmt.Errorf("Address %s is not available on network '%s' : %s", ip, network.Name, err.Error())
+		}
+	} else {
+		ipam, err := getNetworkIPAM(libvirtNetwork)
+		if err != nil {
+			return nil, err
+		}
+		for _, subnet := range ipam.Subnets {
+			if _, ipNet, err := net.ParseCIDR(subnet.CIDR); err == nil && ipNet.IP.To4() != nil {
+				ip, err = findFreeAddress(ipam)
+				if err != nil {
+					return nil, fmt.Errorf("No address available on network '%s' : %s", network.Name, err.Error())
+				}
+				break
+			}
+		}
+	}
+	if ip != "" {
+		err = reserveAddress(libvirtNetwork, mac, ip)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	flags, err := domainModificationFlags(domain)
+	if err != nil {
+		return nil, err
+	}
+	requestXML := `
+	<interface type='network'>
+		<mac address='` + mac + `'/>
+		<source network='` + network.Name + `'/>
+		<model type='virtio'/>` + filterXML + vlansXML + `
+	</interface>`
+	err = domain.AttachDeviceFlags(requestXML, flags)
+	if err != nil {
+		if ip != "" {
+			_ = removeReservations(libvirtNetwork, mac)
+		}
+		return nil, fmt.Errorf("Failed to attach the interface to the domain : %s", err.Error())
+	}
+
+	if ip != "" {
+		err = addDNSHostRecord(libvirtNetwork, ip, []string{domainDescription.Name})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to register host '%s' in the DNS of network '%s' : %s", domainDescription.Name, network.Name, err.Error())
+		}
+	}
+
+	host, err := client.getHostFromDomain(domain, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get host from domain : %s", err.Error())
+	}
+	err = setHostNetworkAttachment(host, network, true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update host network properties : %s", err.Error())
+	}
+	return host, nil
+}
+
+// DetachHostFromNetwork unplugs the interface of the host request.HostID from the network request.NetworkID, its
+// DHCP reservations and DNS records are removed, the default network of the host can't be unplugged
+func (client *Client) DetachHostFromNetwork(request model.HostNetworkRequest) (*model.Host, error) {
+	domain, libvirtNetwork, network, err := client.getDomainAndNetworkFromRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	host, err := client.getHostFromDomain(domain, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get host from domain : %s", err.Error())
+	}
+	hostNetworkV2 := propsv2.NewHostNetwork()
+	err = host.Properties.Get(HostProperty.NetworkV2, hostNetworkV2)
+	if err != nil {
+		return nil, err
+	}
+	if hostNetworkV2.DefaultNetworkID == network.ID {
+		return nil, fmt.Errorf("Network '%s' is the default network of host '%s', it can't be unplugged", network.Name, host.Name)
+	}
+
+	domainDescription, err := getDomainDescription(domain)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := domainModificationFlags(domain)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, iface := range domainDescription.Devices.Interfaces {
+		if iface.Source == nil || iface.Source.Network == nil || iface.Source.Network.Network != network.Name {
+			continue
+		}
+		found = true
+		ifaceXML, err := iface.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to marshal the interface : %s", err.Error())
+		}
+		err = domain.DetachDeviceFlags(ifaceXML, flags)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to detach the interface from the domain : %s", err.Error())
+		}
+		if iface.MAC != nil {
+			err = removeReservations(libvirtNetwork, iface.MAC.Address)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Host '%s' is not plugged on network '%s'", host.Name, network.Name)
+	}
+
+	err = removeDNSHostRecords(libvirtNetwork, []string{domainDescription.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	err = setHostNetworkAttachment(host, network, false)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update host network properties : %s", err.Error())
+	}
+	return host, nil
+}
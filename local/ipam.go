@@ -0,0 +1,334 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+	libvirt "github.com/libvirt/libvirt-go"
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+)
+
+// offsetIP returns the IP address delta addresses after ip (before if delta is negative)
+func offsetIP(ip net.IP, delta int64) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	n := new(big.Int).SetBytes(ip)
+	n.Add(n, big.NewInt(delta))
+
+	bytes := n.Bytes()
+	result := make(net.IP, len(ip))
+	if len(bytes) <= len(result) {
+		copy(result[len(result)-len(bytes):], bytes)
+	}
+	return result
+}
+
+// lastIP returns the last IP address of ipNet (the broadcast address in IPv4)
+func lastIP(ipNet *net.IPNet) net.IP {
+	last := make(net.IP, len(ipNet.IP))
+	for i := range ipNet.IP {
+		last[i] = ipNet.IP[i] | ^ipNet.Mask[i]
+	}
+	return last
+}
+
+// computeSubnet computes the layout of a subnet defined by the driver, whatever the length of its prefix
+// In IPv4 the hypervisor has the network address and DHCP leases from the third address to the last one before broadcast
+// In IPv6 the hypervisor has the first address and DHCP leases from ::100 to ::ffff, or the whole prefix if it is smaller
+func computeSubnet(cidr string) (*propsv1.NetworkSubnet, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse cidr : %s", err.Error())
+	}
+	ones, bits := ipNet.Mask.Size()
+	hostBits := bits - ones
+	last := lastIP(ipNet)
+
+	subnet := &propsv1.NetworkSubnet{
+		CIDR: ipNet.String(),
+	}
+	if bits == 32 {
+		if hostBits < 2 {
+			return nil, fmt.Errorf("Please use a wider network range (at most /30)")
+		}
+		subnet.Gateway = ipNet.IP.String()
+		subnet.PoolStart = offsetIP(ipNet.IP, 2).String()
+		subnet.PoolEnd = offsetIP(last, -1).String()
+	} else {
+		if hostBits < 2 {
+			return nil, fmt.Errorf("Please use a wider IPv6 prefix (at most /126)")
+		}
+		subnet.Gateway = offsetIP(ipNet.IP, 1).String()
+		if hostBits >= 16 {
+			subnet.PoolStart = offsetIP(ipNet.IP, 0x100).String()
+			subnet.PoolEnd = offsetIP(ipNet.IP, 0xffff).String()
+		} else {
+			subnet.PoolStart = offsetIP(ipNet.IP, 2).String()
+			subnet.PoolEnd = last.String()
+		}
+	}
+
+	return subnet, nil
+}
+
+// getNetworkIPPrefix returns the length of the prefix of an ip element of a network description
+func getNetworkIPPrefix(ip libvirtxml.NetworkIP) (int, error) {
+	if ip.Netmask == "" {
+		return int(ip.Prefix), nil
+	}
+	netmask := net.ParseIP(ip.Netmask).To4()
+	if netmask == nil {
+		return 0, fmt.Errorf("Failed to convert x.x.x.x nemask to [0-32] netmask")
+	}
+	prefix, _ := net.IPMask(netmask).Size()
+	return prefix, nil
+}
+
+// reservedMAC returns the placeholder MAC address of the DHCP reservation keeping the IPv4 address ip out of use
+// The locally administered prefix 02:00 is never used by the interfaces of the hosts (52:54:00)
+func reservedMAC(ip net.IP) string {
+	ip4 := ip.To4()
+	return fmt.Sprintf("02:00:%02x:%02x:%02x:%02x", ip4[0], ip4[1], ip4[2], ip4[3])
+}
+
+// isReservedMAC tells if mac is the placeholder MAC address of a reservation
+func isReservedMAC(mac string) bool {
+	return strings.HasPrefix(strings.ToLower(mac), "02:00:")
+}
+
+// getNetworkIPAM builds the addresses management of libvirtNetwork from its definition and its DHCP leases
+func getNetworkIPAM(libvirtNetwork *libvirt.Network) (*propsv1.NetworkIPAM, error) {
+	networkDescription, err := getNetworkDescription(libvirtNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	ipam := propsv1.NewNetworkIPAM()
+	for _, ip := range networkDescription.IPs {
+		prefix, err := getNetworkIPPrefix(ip)
+		if err != nil {
+			return nil, err
+		}
+		_, ipNet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", ip.Address, prefix))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse the subnet of the network : %s", err.Error())
+		}
+
+		subnet := &propsv1.NetworkSubnet{
+			CIDR:    ipNet.String(),
+			Gateway: ip.Address,
+		}
+		if ip.DHCP != nil {
+			if len(ip.DHCP.Ranges) > 0 {
+				subnet.PoolStart = ip.DHCP.Ranges[0].Start
+				subnet.PoolEnd = ip.DHCP.Ranges[0].End
+			}
+			for _, host := range ip.DHCP.Hosts {
+				if host.IP == "" {
+					continue
+				}
+				if isReservedMAC(host.MAC) {
+					ipam.Reserved[host.IP] = host.MAC
+				} else {
+					ipam.Static[host.IP] = host.MAC
+				}
+			}
+		}
+		ipam.Subnets = append(ipam.Subnets, subnet)
+	}
+
+	active, err := libvirtNetwork.IsActive()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get network state : %s", err.Error())
+	}
+	if active {
+		leases, err := libvirtNetwork.GetDHCPLeases()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get the DHCP leases of the network : %s", err.Error())
+		}
+		for _, lease := range leases {
+			ipam.Allocated[lease.IPaddr] = lease.Mac
+		}
+	}
+	ipam.UpdatedAt = time.Now()
+
+	return ipam, nil
+}
+
+// checkAddress checks that ip belongs to a subnet of ipam and is neither reserved nor leased
+func checkAddress(ipam *propsv1.NetworkIPAM, ip string) error {
+	address := net.ParseIP(ip)
+	if address == nil {
+		return fmt.Errorf("%s is not an IP address", ip)
+	}
+
+	var subnet *propsv1.NetworkSubnet
+	var ipNet *net.IPNet
+	for _, s := range ipam.Subnets {
+		_, n, err := net.ParseCIDR(s.CIDR)
+		if err == nil && n.Contains(address) {
+			subnet, ipNet = s, n
+			break
+		}
+	}
+	if subnet == nil {
+		return fmt.Errorf("%s is outside of the subnets of the network", ip)
+	}
+
+	unusable := address.Equal(ipNet.IP) || address.Equal(net.ParseIP(subnet.Gateway))
+	if address.To4() != nil {
+		unusable = unusable || address.Equal(lastIP(ipNet))
+	}
+	if unusable {
+		return fmt.Errorf("%s is not usable by a host of the subnet %s", ip, subnet.CIDR)
+	}
+
+	for reservedIP := range ipam.Reserved {
+		if address.Equal(net.ParseIP(reservedIP)) {
+			return fmt.Errorf("%s is already reserved", ip)
+		}
+	}
+	for staticIP, mac := range ipam.Static {
+		if address.Equal(net.ParseIP(staticIP)) {
+			return fmt.Errorf("%s is already reserved for the interface %s", ip, mac)
+		}
+	}
+	for allocatedIP, mac := range ipam.Allocated {
+		if address.Equal(net.ParseIP(allocatedIP)) {
+			return fmt.Errorf("%s is already leased to %s", ip, mac)
+		}
+	}
+
+	return nil
+}
+
+// findFreeAddress returns the highest IPv4 address of the DHCP pools of ipam which is neither reserved nor leased,
+// dynamic leases being given from the bottom of the pools
+func findFreeAddress(ipam *propsv1.NetworkIPAM) (string, error) {
+	for _, subnet := range ipam.Subnets {
+		_, ipNet, err := net.ParseCIDR(subnet.CIDR)
+		if err != nil || ipNet.IP.To4() == nil {
+			continue
+		}
+		first, last := offsetIP(ipNet.IP, 2), offsetIP(lastIP(ipNet), -1)
+		if subnet.PoolStart != "" && subnet.PoolEnd != "" {
+			first, last = net.ParseIP(subnet.PoolStart), net.ParseIP(subnet.PoolEnd)
+		}
+		for ip := last; ipNet.Contains(ip) && bytes.Compare(ip.To4(), first.To4()) >= 0; ip = offsetIP(ip, -1) {
+			if checkAddress(ipam, ip.String()) == nil {
+				return ip.String(), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("No free IPv4 address left in the network")
+}
+
+// GetNetworkIPAM returns the subnets and the reserved, static and allocated addresses of the network identified by ref (id or name)
+func (client *Client) GetNetworkIPAM(ref string) (*propsv1.NetworkIPAM, error) {
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return nil, err
+	}
+
+	return getNetworkIPAM(libvirtNetwork)
+}
+
+// ReserveNetworkAddress keeps the IPv4 address ip of the network identified by ref (id or name) out of use
+// The address is bound in DHCP to a placeholder MAC address, so that it is never leased
+func (client *Client) ReserveNetworkAddress(ref string, ip string) error {
+	address := net.ParseIP(ip)
+	if address == nil || address.To4() == nil {
+		return fmt.Errorf("%s is not an IPv4 address, only IPv4 addresses can be reserved", ip)
+	}
+
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return err
+	}
+	ipam, err := getNetworkIPAM(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+	err = checkAddress(ipam, ip)
+	if err != nil {
+		return err
+	}
+
+	return reserveAddress(libvirtNetwork, reservedMAC(address), ip)
+}
+
+// ReserveFreeNetworkAddress reserves the last free IPv4 address of the network identified by ref (id or name)
+// and returns it, the addresses are taken from the end of the DHCP pool to stay away from the first leases
+func (client *Client) ReserveFreeNetworkAddress(ref string) (string, error) {
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return "", err
+	}
+	ipam, err := getNetworkIPAM(libvirtNetwork)
+	if err != nil {
+		return "", err
+	}
+
+	ip, err := findFreeAddress(ipam)
+	if err != nil {
+		return "", err
+	}
+	err = reserveAddress(libvirtNetwork, reservedMAC(net.ParseIP(ip)), ip)
+	if err != nil {
+		return "", err
+	}
+	return ip, nil
+}
+
+// ReleaseNetworkAddress releases the address ip of the network identified by ref (id or name), reserved by ReserveNetworkAddress
+func (client *Client) ReleaseNetworkAddress(ref string, ip string) error {
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return err
+	}
+	ipam, err := getNetworkIPAM(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+
+	if mac, ok := ipam.Static[ip]; ok {
+		return fmt.Errorf("%s is reserved for the interface %s of a host, delete the host to release it", ip, mac)
+	}
+	if _, ok := ipam.Reserved[ip]; !ok {
+		return fmt.Errorf("%s is not reserved", ip)
+	}
+
+	flags, err := networkUpdateFlags(libvirtNetwork)
+	if err != nil {
+		return err
+	}
+	err = libvirtNetwork.Update(libvirt.NETWORK_UPDATE_COMMAND_DELETE, libvirt.NETWORK_SECTION_IP_DHCP_HOST, -1, dhcpHostXML(ipam.Reserved[ip], ip), flags)
+	if err != nil {
+		return fmt.Errorf("Failed to release %s : %s", ip, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"net"
+	"testing"
+
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+)
+
+func TestOffsetIP(t *testing.T) {
+	tests := []struct {
+		ip    string
+		delta int64
+		want  string
+	}{
+		{"10.0.0.1", 1, "10.0.0.2"},
+		{"10.0.0.255", 1, "10.0.1.0"},
+		{"10.0.1.0", -1, "10.0.0.255"},
+		{"192.168.1.254", -252, "192.168.1.2"},
+		{"fd00::", 0x100, "fd00::100"},
+		{"fd00::ffff", 1, "fd00::1:0"},
+		{"fd00::1:0", -1, "fd00::ffff"},
+	}
+	for _, test := range tests {
+		got := offsetIP(net.ParseIP(test.ip), test.delta)
+		if got.String() != test.want {
+			t.Errorf("offsetIP(%s, %d) = %s, want %s", test.ip, test.delta, got, test.want)
+		}
+		if net.ParseIP(test.ip).To4() != nil && len(got) != net.IPv4len {
+			t.Errorf("offsetIP(%s, %d) returned %d bytes, want %d", test.ip, test.delta, len(got), net.IPv4len)
+		}
+	}
+}
+
+func TestComputeSubnet(t *testing.T) {
+	tests := []struct {
+		cidr      string
+		wantErr   bool
+		subnet    string
+		gateway   string
+		poolStart string
+		poolEnd   string
+	}{
+		{cidr: "192.168.1.0/24", subnet: "192.168.1.0/24", gateway: "192.168.1.0", poolStart: "192.168.1.2", poolEnd: "192.168.1.254"},
+		{cidr: "192.168.1.17/24", subnet: "192.168.1.0/24", gateway: "192.168.1.0", poolStart: "192.168.1.2", poolEnd: "192.168.1.254"},
+		{cidr: "10.0.0.0/16", subnet: "10.0.0.0/16", gateway: "10.0.0.0", poolStart: "10.0.0.2", poolEnd: "10.0.255.254"},
+		{cidr: "10.0.0.4/30", subnet: "10.0.0.4/30", gateway: "10.0.0.4", poolStart: "10.0.0.6", poolEnd: "10.0.0.6"},
+		{cidr: "10.0.0.4/31", wantErr: true},
+		{cidr: "10.0.0.4/32", wantErr: true},
+		{cidr: "fd00::/64", subnet: "fd00::/64", gateway: "fd00::1", poolStart: "fd00::100", poolEnd: "fd00::ffff"},
+		{cidr: "fd00::/112", subnet: "fd00::/112", gateway: "fd00::1", poolStart: "fd00::100", poolEnd: "fd00::ffff"},
+		{cidr: "fd00::/120", subnet: "fd00::/120", gateway: "fd00::1", poolStart: "fd00::2", poolEnd: "fd00::ff"},
+		{cidr: "fd00::/126", subnet: "fd00::/126", gateway: "fd00::1", poolStart: "fd00::2", poolEnd: "fd00::3"},
+		{cidr: "fd00::/127", wantErr: true},
+		{cidr: "192.168.1.0", wantErr: true},
+	}
+	for _, test := range tests {
+		subnet, err := computeSubnet(test.cidr)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("computeSubnet(%s) succeeded, want an error", test.cidr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("computeSubnet(%s) failed : %s", test.cidr, err.Error())
+			continue
+		}
+		if subnet.CIDR != test.subnet || subnet.Gateway != test.gateway || subnet.PoolStart != test.poolStart || subnet.PoolEnd != test.poolEnd {
+			t.Errorf("computeSubnet(%s) = %s gw %s pool %s-%s, want %s gw %s pool %s-%s", test.cidr,
+				subnet.CIDR, subnet.Gateway, subnet.PoolStart, subnet.PoolEnd,
+				test.subnet, test.gateway, test.poolStart, test.poolEnd)
+		}
+	}
+}
+
+// newTestIPAM returns the addresses management of a dual-stack network having some reserved, static and leased addresses
+func newTestIPAM() *propsv1.NetworkIPAM {
+	ipam := propsv1.NewNetworkIPAM()
+	ipam.Subnets = []*propsv1.NetworkSubnet{
+		{CIDR: "192.168.1.0/24", Gateway: "192.168.1.1", PoolStart: "192.168.1.2", PoolEnd: "192.168.1.254"},
+		{CIDR: "fd00::/64", Gateway: "fd00::1", PoolStart: "fd00::100", PoolEnd: "fd00::ffff"},
+	}
+	ipam.Reserved["192.168.1.254"] = reservedMAC(net.ParseIP("192.168.1.254"))
+	ipam.Static["192.168.1.10"] = "52:54:00:00:00:10"
+	ipam.Allocated["192.168.1.20"] = "52:54:00:00:00:20"
+	ipam.Allocated["fd00::120"] = "52:54:00:00:01:20"
+	return ipam
+}
+
+func TestCheckAddress(t *testing.T) {
+	tests := []struct {
+		ip      string
+		wantErr bool
+	}{
+		{"192.168.1.5", false},
+		{"192.168.1.253", false},
+		{"192.168.1.0", true},   // network address
+		{"192.168.1.1", true},   // gateway
+		{"192.168.1.255", true}, // broadcast
+		{"192.168.1.254", true}, // reserved
+		{"192.168.1.10", true},  // static
+		{"192.168.1.20", true},  // leased
+		{"192.168.2.5", true},   // outside of the subnets
+		{"fd00::200", false},
+		{"fd00::ffff:ffff:ffff:ffff", false}, // no broadcast in IPv6
+		{"fd00::", true},
+		{"fd00::1", true},
+		{"fd00::120", true},
+		{"not-an-ip", true},
+	}
+	ipam := newTestIPAM()
+	for _, test := range tests {
+		err := checkAddress(ipam, test.ip)
+		if test.wantErr && err == nil {
+			t.Errorf("checkAddress(%s) succeeded, want an error", test.ip)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("checkAddress(%s) failed : %s", test.ip, err.Error())
+		}
+	}
+}
+
+func TestFindFreeAddress(t *testing.T) {
+	full := propsv1.NewNetworkIPAM()
+	full.Subnets = []*propsv1.NetworkSubnet{
+		{CIDR: "10.0.0.4/30", Gateway: "10.0.0.4", PoolStart: "10.0.0.6", PoolEnd: "10.0.0.6"},
+	}
+	full.Allocated["10.0.0.6"] = "52:54:00:00:00:06"
+
+	noPool := propsv1.NewNetworkIPAM()
+	noPool.Subnets = []*propsv1.NetworkSubnet{
+		{CIDR: "10.0.0.0/29", Gateway: "10.0.0.1"},
+	}
+
+	ipv6Only := propsv1.NewNetworkIPAM()
+	ipv6Only.Subnets = []*propsv1.NetworkSubnet{
+		{CIDR: "fd00::/64", Gateway: "fd00::1", PoolStart: "fd00::100", PoolEnd: "fd00::ffff"},
+	}
+
+	tests := []struct {
+		name    string
+		ipam    *propsv1.NetworkIPAM
+		want    string
+		wantErr bool
+	}{
+		{name: "end of the pool", ipam: newTestIPAM(), want: "192.168.1.253"},
+		{name: "whole subnet without pool", ipam: noPool, want: "10.0.0.6"},
+		{name: "exhausted pool", ipam: full, wantErr: true},
+		{name: "IPv6 only", ipam: ipv6Only, wantErr: true},
+	}
+	for _, test := range tests {
+		ip, err := findFreeAddress(test.ipam)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s : findFreeAddress returned %s, want an error", test.name, ip)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s : findFreeAddress failed : %s", test.name, err.Error())
+			continue
+		}
+		if ip != test.want {
+			t.Errorf("%s : findFreeAddress returned %s, want %s", test.name, ip, test.want)
+		}
+	}
+}
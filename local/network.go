@@ -19,30 +19,45 @@ package local
 import (
 	"encoding/xml"
 	"fmt"
-	"math"
 	"net"
-	"strconv"
 	"strings"
 
 	"github.com/CS-SI/LocalDriver/model"
 	"github.com/CS-SI/LocalDriver/model/enums/IPVersion"
+	"github.com/CS-SI/LocalDriver/model/enums/IPv6Mode"
+	"github.com/CS-SI/LocalDriver/model/enums/NetworkMode"
+	"github.com/CS-SI/LocalDriver/model/enums/NetworkProperty"
 	libvirt "github.com/libvirt/libvirt-go"
 	libvirtxml "github.com/libvirt/libvirt-go-xml"
 )
 
+// infoFromCidr returns the address of the hypervisor in the IPv4 subnet cidr, its netmask and the DHCP range
 func infoFromCidr(cidr string) (string, string, string, string, error) {
-	_, IPNet, err := net.ParseCIDR(cidr)
+	subnet, err := computeSubnet(cidr)
 	if err != nil {
-		return "", "", "", "", fmt.Errorf("Failed to parse cidr : ", err.Error())
-	} else if IPNet.Mask[3] >= 63 {
-		return "", "", "", "", fmt.Errorf("Please use a wider network range")
+		return "", "", "", "", err
 	}
+	_, IPNet, err := net.ParseCIDR(subnet.CIDR)
+	if err != nil || IPNet.IP.To4() == nil {
+		return "", "", "", "", fmt.Errorf("%s is not an IPv4 cidr", cidr)
+	}
+
+	return subnet.Gateway, net.IP(IPNet.Mask).String(), subnet.PoolStart, subnet.PoolEnd, nil
+}
 
-	mask := fmt.Sprintf("%d.%d.%d.%d", IPNet.Mask[0], IPNet.Mask[1], IPNet.Mask[2], IPNet.Mask[3])
-	dhcpStart := fmt.Sprintf("%d.%d.%d.%d", IPNet.IP[0], IPNet.IP[1], IPNet.IP[2], IPNet.IP[3]+2)
-	dhcpEnd := fmt.Sprintf("%d.%d.%d.%d", IPNet.IP[0]+(255-IPNet.Mask[0]), IPNet.IP[1]+(255-IPNet.Mask[1]), IPNet.IP[2]+(255-IPNet.Mask[2]), IPNet.IP[3]+(255-IPNet.Mask[3]-1))
+// infoFromCidrV6 returns the address of the hypervisor in the IPv6 prefix cidr, the length of the prefix and the DHCPv6 range
+func infoFromCidrV6(cidr string) (string, int, string, string, error) {
+	subnet, err := computeSubnet(cidr)
+	if err != nil {
+		return "", 0, "", "", err
+	}
+	_, IPNet, err := net.ParseCIDR(subnet.CIDR)
+	if err != nil || IPNet.IP.To4() != nil {
+		return "", 0, "", "", fmt.Errorf("%s is not an IPv6 prefix", cidr)
+	}
+	prefix, _ := IPNet.Mask.Size()
 
-	return IPNet.IP.String(), mask, dhcpStart, dhcpEnd, nil
+	return subnet.Gateway, prefix, subnet.PoolStart, subnet.PoolEnd, nil
 }
 
 func getNetworkFromRef(ref string, libvirtService *libvirt.Connect) (*libvirt.Network, error) {
@@ -57,7 +72,8 @@ func getNetworkFromRef(ref string, libvirtService *libvirt.Connect) (*libvirt.Ne
 	return libvirtNetwork, nil
 }
 
-func getNetworkFromLibvirtNetwork(libvirtNetwork *libvirt.Network) (*model.Network, error) {
+// getNetworkDescription returns the xml description of libvirtNetwork
+func getNetworkDescription(libvirtNetwork *libvirt.Network) (*libvirtxml.Network, error) {
 	libvirtNetworkXML, err := libvirtNetwork.GetXMLDesc(0)
 	if err != nil {
 		return nil, fmt.Errorf(fmt.Sprintf("Failed get network's xml description  : %s", err.Error()))
@@ -68,83 +84,257 @@ func getNetworkFromLibvirtNetwork(libvirtNetwork *libvirt.Network) (*model.Netwo
 		return nil, fmt.Errorf(fmt.Sprintf("Failed get Unmarshal networks's xml description  : %s", err.Error()))
 	}
 
-	var ipVersion IPVersion.Enum
-	if networkDescription.IPv6 == "" {
-		ipVersion = IPVersion.IPv4
-	} else {
-		ipVersion = IPVersion.IPv6
+	return networkDescription, nil
+}
+
+func getNetworkFromLibvirtNetwork(libvirtNetwork *libvirt.Network) (*model.Network, error) {
+	networkDescription, err := getNetworkDescription(libvirtNetwork)
+	if err != nil {
+		return nil, err
 	}
 
-	cidr := ""
-	if ipVersion == IPVersion.IPv4 {
-		netmaskBloc := strings.Split(networkDescription.IPs[0].Netmask, ".")
-		netmaskInt := 0
-		for i := 0; i < 4; i++ {
-			value, err := strconv.Atoi(netmaskBloc[i])
+	network := model.NewNetwork()
+	network.ID = networkDescription.UUID
+	network.Name = networkDescription.Name
+
+	for _, ip := range networkDescription.IPs {
+		if ip.Family == "ipv6" {
+			_, ipNet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", ip.Address, ip.Prefix))
 			if err != nil {
-				return nil, fmt.Errorf("Failed to convert x.x.x.x nemask to [0-32] netmask")
+				return nil, fmt.Errorf("Failed to parse the IPv6 prefix of the network : %s", err.Error())
+			}
+			network.IPv6CIDR = ipNet.String()
+			// Without DHCPv6 range, libvirt only sends router advertisements
+			if ip.DHCP != nil && len(ip.DHCP.Ranges) > 0 {
+				network.IPv6Mode = IPv6Mode.DHCP
+			} else {
+				network.IPv6Mode = IPv6Mode.SLAAC
 			}
-			if value != 0 {
-				netmaskInt += int(math.Log2(float64(value)) + 1)
+		} else if network.CIDR == "" {
+			prefix, err := getNetworkIPPrefix(ip)
+			if err != nil {
+				return nil, err
 			}
+			network.CIDR = fmt.Sprintf("%s/%d", ip.Address, prefix)
 		}
-		cidr = fmt.Sprintf("%s/%d", networkDescription.IPs[0].Address, netmaskInt)
+	}
+
+	if network.CIDR != "" || network.IPv6CIDR == "" {
+		network.IPVersion = IPVersion.IPv4
 	} else {
-		cidr = networkDescription.IPv6
+		network.IPVersion = IPVersion.IPv6
+		network.CIDR = network.IPv6CIDR
 	}
 
-	network := model.NewNetwork()
-	network.ID = networkDescription.UUID
-	network.Name = networkDescription.Name
-	network.CIDR = cidr
-	network.IPVersion = ipVersion
+	network.Mode = NetworkMode.ISOLATED
+	if networkDescription.Forward != nil {
+		// The other forward modes of libvirt (passthrough, vepa...) are not created by the driver and are reported as isolated
+		if mode, err := NetworkMode.Parse(networkDescription.Forward.Mode); err == nil {
+			network.Mode = mode
+		}
+	}
+	if networkDescription.Bridge != nil {
+		network.Bridge = networkDescription.Bridge.Name
+	}
+	network.VirtualPort, network.VLANs, err = getNetworkPortDescription(libvirtNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	if networkDescription.Domain != nil {
+		network.DNSDomain = networkDescription.Domain.Name
+	}
+	if networkDescription.DNS != nil {
+		for _, forwarder := range networkDescription.DNS.Forwarders {
+			if forwarder.Addr != "" {
+				network.DNSServers = append(network.DNSServers, forwarder.Addr)
+			}
+		}
+	}
 	//network.GatewayID
 	//network.Properties
 
 	return network, nil
 }
 
-// CreateNetwork creates a network named name
-func (client *Client) CreateNetwork(req model.NetworkRequest) (*model.Network, error) {
+// networkAddressingXML returns the xml of the subnets, the DHCP and the DNS of the network requested by req
+func (client *Client) networkAddressingXML(req model.NetworkRequest) (string, error) {
 	name := req.Name
 	ipVersion := req.IPVersion
 	cidr := req.CIDR
+	ipv6CIDR := req.IPv6CIDR
 	dns := req.DNSServers
+	dnsDomain := req.DNSDomain
+
+	switch ipVersion {
+	case IPVersion.IPv4:
+		if IPVersion.IPv6.Is(strings.Split(cidr, "/")[0]) {
+			return "", fmt.Errorf("%s is not an IPv4 cidr, use an IPv6 network", cidr)
+		}
+	case IPVersion.IPv6:
+		// An IPv6 only network has no IPv4 range
+		if ipv6CIDR == "" {
+			ipv6CIDR = cidr
+		}
+		cidr = ""
+	default:
+		return "", fmt.Errorf("Unknown IP version %s", ipVersion.String())
+	}
+	for _, server := range dns {
+		if net.ParseIP(server) == nil {
+			return "", fmt.Errorf("DNS server %s is not an IP address", server)
+		}
+	}
+	if dnsDomain == "" {
+		dnsDomain = name + ".local"
+	}
 
-	//TODO check what permanant/autostart means
+	// The subnets must not overlap the ones already routed by the hypervisor
+	used, err := client.getUsedSubnets()
+	if err != nil {
+		return "", err
+	}
+	if cidr == "" && ipVersion == IPVersion.IPv4 {
+		cidr, err = allocateSubnet(client.Config.SupernetPool, client.Config.SubnetPrefix, used)
+		if err != nil {
+			return "", err
+		}
+	} else if cidr != "" {
+		err = checkSubnetOverlap(cidr, used)
+		if err != nil {
+			return "", err
+		}
+	}
+	if ipv6CIDR == "" && ipVersion == IPVersion.IPv6 {
+		return "", fmt.Errorf("The CIDR of an IPv6 network is mandatory")
+	}
+	if ipv6CIDR != "" {
+		err = checkSubnetOverlap(ipv6CIDR, used)
+		if err != nil {
+			return "", err
+		}
+	}
 
-	if ipVersion != IPVersion.IPv4 {
-		// TODO implement IPV6 networks
-		panic("only ipv4 networks are implemented")
+	ipsXML := ""
+	if cidr != "" {
+		ip, netmask, dhcpStart, dhcpEnd, err := infoFromCidr(cidr)
+		if err != nil {
+			return "", err
+		}
+		ipsXML += `
+		<ip address="` + ip + `" netmask="` + netmask + `">
+			<dhcp> 
+				<range start="` + dhcpStart + `" end="` + dhcpEnd + `" />
+			</dhcp>
+		</ip>`
+	}
+	if ipv6CIDR != "" {
+		ip, prefix, dhcpStart, dhcpEnd, err := infoFromCidrV6(ipv6CIDR)
+		if err != nil {
+			return "", err
+		}
+		dhcpXML := ""
+		switch req.IPv6Mode {
+		case IPv6Mode.DHCP:
+			dhcpXML = `
+			<dhcp>
+				<range start="` + dhcpStart + `" end="` + dhcpEnd + `" />
+			</dhcp>`
+		case IPv6Mode.SLAAC:
+			if prefix != 64 {
+				return "", fmt.Errorf("SLAAC needs a /64 IPv6 prefix")
+			}
+		default:
+			return "", fmt.Errorf("Unknown IPv6 mode %s", req.IPv6Mode.String())
+		}
+		ipsXML += `
+		<ip family="ipv6" address="` + ip + `" prefix="` + fmt.Sprintf("%d", prefix) + `">` + dhcpXML + `
+		</ip>`
 	}
-	if len(dns) != 0 {
-		// TODO implement DNS for networks
-		panic("DNS not implemented yet in networks creation")
+
+	// The names of the domain are resolved by the network, the other names are forwarded
+	dnsXML := `
+		<domain name="` + dnsDomain + `" localOnly="yes"/>
+		<dns>`
+	for _, server := range dns {
+		dnsXML += `
+			<forwarder addr="` + server + `"/>`
 	}
+	dnsXML += `
+		</dns>`
+
+	return dnsXML + ipsXML, nil
+}
+
+// CreateNetwork creates a network named name
+func (client *Client) CreateNetwork(req model.NetworkRequest) (*model.Network, error) {
+	name := req.Name
 
 	libvirtNetwork, err := getNetworkFromRef(name, client.LibvirtService)
 	if libvirtNetwork != nil {
 		return nil, fmt.Errorf("Network %s already exists !", name)
 	}
 
-	ip, netmask, dhcpStart, dhcpEnd, err := infoFromCidr(cidr)
-	if err != nil {
-		return nil, err
+	forwardXML := ""
+	addressingXML := ""
+	switch req.Mode {
+	case NetworkMode.ISOLATED:
+	case NetworkMode.NAT, NetworkMode.ROUTE, NetworkMode.OPEN:
+		forwardXML = `
+		<forward mode="` + strings.ToLower(req.Mode.String()) + `"/>`
+	case NetworkMode.BRIDGE:
+		// The addresses of the hosts plugged on an existing bridge are managed outside of libvirt
+		if req.Bridge == "" {
+			return nil, fmt.Errorf("The bridge of a bridge network is mandatory")
+		}
+		if req.CIDR != "" || req.IPv6CIDR != "" || len(req.DNSServers) != 0 {
+			return nil, fmt.Errorf("The addresses of a bridge network are not managed by libvirt, no CIDR nor DNS can be given")
+		}
+		portXML, err := bridgePortXML(req.Bridge, req.VLANs)
+		if err != nil {
+			return nil, err
+		}
+		forwardXML = `
+		<forward mode="bridge"/>
+		<bridge name="` + req.Bridge + `"/>` + portXML
+	default:
+		return nil, fmt.Errorf("Unknown network mode %s", req.Mode.String())
+	}
+	if req.Mode != NetworkMode.BRIDGE && len(req.VLANs) != 0 {
+		return nil, fmt.Errorf("Only the ports of a bridge network can be tagged with VLANs")
+	}
+	if req.Mode != NetworkMode.BRIDGE {
+		addressingXML, err = client.networkAddressingXML(req)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	requestXML := `
 	<network>
-		<name>` + name + `</name>
-		<ip address="` + ip + `" netmask="` + netmask + `">
-			<dhcp> 
-				<range start="` + dhcpStart + `" end="` + dhcpEnd + `" />
-			</dhcp>
-		</ip>
+		<name>` + name + `</name>` + forwardXML + addressingXML + `
 	</network>`
 
-	libvirtNetwork, err = client.LibvirtService.NetworkCreateXML(requestXML)
+	libvirtNetwork, err = client.LibvirtService.NetworkDefineXML(requestXML)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to define network : %s", err.Error())
+	}
+	err = libvirtNetwork.Create()
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create network : ", err.Error())
+		if errUndefine := libvirtNetwork.Undefine(); errUndefine != nil {
+			return nil, fmt.Errorf("Failed to start network : %s, and failed to undefine it : %s", err.Error(), errUndefine.Error())
+		}
+		return nil, fmt.Errorf("Failed to start network : %s", err.Error())
+	}
+	err = libvirtNetwork.SetAutostart(true)
+	if err != nil {
+		if errDestroy := libvirtNetwork.Destroy(); errDestroy != nil {
+			return nil, fmt.Errorf("Failed to enable network autostart : %s, and failed to stop it : %s", err.Error(), errDestroy.Error())
+		}
+		if errUndefine := libvirtNetwork.Undefine(); errUndefine != nil {
+			return nil, fmt.Errorf("Failed to enable network autostart : %s, and failed to undefine it : %s", err.Error(), errUndefine.Error())
+		}
+		return nil, fmt.Errorf("Failed to enable network autostart : %s", err.Error())
 	}
 
 	network, err := getNetworkFromLibvirtNetwork(libvirtNetwork)
@@ -152,6 +342,12 @@ func (client *Client) CreateNetwork(req model.NetworkRequest) (*model.Network, e
 		return nil, fmt.Errorf("Failed to convert a libvirt network into a network : ", err.Error())
 	}
 
+	ipam, err := getNetworkIPAM(libvirtNetwork)
+	if err != nil {
+		return nil, err
+	}
+	network.Properties.Set(NetworkProperty.IPAMV1, ipam)
+
 	return network, nil
 }
 
@@ -167,6 +363,12 @@ func (client *Client) GetNetwork(ref string) (*model.Network, error) {
 		return nil, fmt.Errorf("Failed to convert a libvirt network into a network : ", err.Error())
 	}
 
+	ipam, err := getNetworkIPAM(libvirtNetwork)
+	if err != nil {
+		return nil, err
+	}
+	network.Properties.Set(NetworkProperty.IPAMV1, ipam)
+
 	return network, nil
 }
 
@@ -202,14 +404,163 @@ func (client *Client) DeleteNetwork(ref string) error {
 		return err
 	}
 
+	active, err := libvirtNetwork.IsActive()
+	if err != nil {
+		return fmt.Errorf("Failed to get network state : %s", err.Error())
+	}
+	// A transient network vanishes once destroyed, a persistent one has to be undefined too
+	persistent, err := libvirtNetwork.IsPersistent()
+	if err != nil {
+		return fmt.Errorf("Failed to know if the network is persistent : %s", err.Error())
+	}
+
+	if active {
+		err = libvirtNetwork.Destroy()
+		if err != nil {
+			return fmt.Errorf("Failed to destroy network : %s", err.Error())
+		}
+	}
+	if persistent {
+		err = libvirtNetwork.Undefine()
+		if err != nil {
+			return fmt.Errorf("Failed to undefine network : %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// StartNetwork starts the persistent network identified by ref (id or name)
+func (client *Client) StartNetwork(ref string) error {
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return err
+	}
+
+	active, err := libvirtNetwork.IsActive()
+	if err != nil {
+		return fmt.Errorf("Failed to get network state : %s", err.Error())
+	}
+	if active {
+		return nil
+	}
+
+	err = libvirtNetwork.Create()
+	if err != nil {
+		return fmt.Errorf("Failed to start network : %s", err.Error())
+	}
+
+	return nil
+}
+
+// StopNetwork stops the network identified by ref (id or name), a persistent network stays defined
+func (client *Client) StopNetwork(ref string) error {
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return err
+	}
+
+	persistent, err := libvirtNetwork.IsPersistent()
+	if err != nil {
+		return fmt.Errorf("Failed to know if the network is persistent : %s", err.Error())
+	}
+	if !persistent {
+		return fmt.Errorf("Network %s is transient and would be lost if stopped, make it persistent first", ref)
+	}
+
+	active, err := libvirtNetwork.IsActive()
+	if err != nil {
+		return fmt.Errorf("Failed to get network state : %s", err.Error())
+	}
+	if !active {
+		return nil
+	}
+
 	err = libvirtNetwork.Destroy()
 	if err != nil {
-		return fmt.Errorf("Failed to destroy network : ", err.Error())
+		return fmt.Errorf("Failed to stop network : %s", err.Error())
+	}
+
+	return nil
+}
+
+// SetNetworkAutostart enables or disables the start of the network identified by ref (id or name) with libvirtd
+// A transient network is made persistent before enabling its autostart
+func (client *Client) SetNetworkAutostart(ref string, autostart bool) error {
+	if autostart {
+		err := client.PersistNetwork(ref)
+		if err != nil {
+			return err
+		}
+	}
+
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return err
+	}
+
+	persistent, err := libvirtNetwork.IsPersistent()
+	if err != nil {
+		return fmt.Errorf("Failed to know if the network is persistent : %s", err.Error())
+	}
+	if !persistent {
+		// A transient network never autostarts
+		return nil
+	}
+
+	err = libvirtNetwork.SetAutostart(autostart)
+	if err != nil {
+		return fmt.Errorf("Failed to set network autostart : %s", err.Error())
+	}
+
+	return nil
+}
+
+// PersistNetwork turns the transient network identified by ref (id or name), created by older versions, into a persistent one
+// The network keeps running during the operation
+func (client *Client) PersistNetwork(ref string) error {
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return err
+	}
+
+	persistent, err := libvirtNetwork.IsPersistent()
+	if err != nil {
+		return fmt.Errorf("Failed to know if the network is persistent : %s", err.Error())
+	}
+	if persistent {
+		return nil
+	}
+
+	libvirtNetworkXML, err := libvirtNetwork.GetXMLDesc(libvirt.NETWORK_XML_INACTIVE)
+	if err != nil {
+		return fmt.Errorf("Failed get network's xml description : %s", err.Error())
+	}
+
+	// Defining a running transient network with the same name and uuid makes it persistent
+	_, err = client.LibvirtService.NetworkDefineXML(libvirtNetworkXML)
+	if err != nil {
+		return fmt.Errorf("Failed to define network : %s", err.Error())
 	}
 
 	return nil
 }
 
+// IsNetworkPersistent tells if the network identified by ref (id or name) survives a restart of libvirtd
+func (client *Client) IsNetworkPersistent(ref string) (bool, error) {
+	libvirtNetwork, err := getNetworkFromRef(ref, client.LibvirtService)
+	if err != nil {
+		return false, err
+	}
+
+	persistent, err := libvirtNetwork.IsPersistent()
+	if err != nil {
+		return false, fmt.Errorf("Failed to know if the network is persistent : %s", err.Error())
+	}
+
+	return persistent, nil
+}
+
 // CreateGateway creates a public Gateway for a private network
 func (client *Client) CreateGateway(req model.GatewayRequest) (*model.Host, error) {
 	network := req.Network
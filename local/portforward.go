@@ -0,0 +1,207 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CS-SI/LocalDriver/model/enums/NetworkMode"
+	"github.com/CS-SI/LocalDriver/model/enums/PortForwardMode"
+	"github.com/CS-SI/LocalDriver/model/enums/RuleProtocol"
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+)
+
+// portForwardTable is the nftables table of the hypervisor holding the port forwards
+const portForwardTable = "virt_portforward"
+
+// portForwardRulesFile holds the port forwards of the hypervisor, loaded back at boot by portForwardUnit
+const portForwardRulesFile = "/etc/virt/portforward.nft"
+
+// portForwardUnitFile is the systemd unit restoring the port forwards of the hypervisor at boot
+const portForwardUnitFile = "/etc/systemd/system/virt-portforward.service"
+
+// portForwardUnit restores the table of the port forwards once the networks of libvirt are up
+var portForwardUnit = fmt.Sprintf(`[Unit]
+Description=Port forwards of the hypervisor to the hosts
+After=network.target libvirtd.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStartPre=-/usr/sbin/nft delete table ip %s
+ExecStart=/usr/sbin/nft -f %s
+
+[Install]
+WantedBy=multi-user.target
+`, portForwardTable, portForwardRulesFile)
+
+// nftHandleRegexp extracts the handle of a rule from the output of 'nft -a list'
+var nftHandleRegexp = regexp.MustCompile(`# handle (\d+)`)
+
+// runNft runs nft with args on the hypervisor
+func runNft(args ...string) (string, error) {
+	output, err := exec.Command("nft", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("'nft %s' failed : %s : %s", strings.Join(args, " "), err.Error(), strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// ensurePortForwardTable creates, if needed, the nftables table and chains of the port forwards
+// 'nft add' leaves an existing table or chain untouched
+func ensurePortForwardTable() error {
+	commands := [][]string{
+		{"add", "table", "ip", portForwardTable},
+		{"add", "chain", "ip", portForwardTable, "prerouting", "{ type nat hook prerouting priority -100 ; }"},
+		{"add", "chain", "ip", portForwardTable, "forward", "{ type filter hook forward priority 0 ; }"},
+	}
+	for _, args := range commands {
+		_, err := runNft(args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// savePortForwardTable writes the table of the port forwards in portForwardRulesFile, and installs the unit loading it
+// at boot if needed, the nftables rules being lost when the hypervisor reboots
+func savePortForwardTable() error {
+	ruleset, err := runNft("list", "table", "ip", portForwardTable)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(portForwardRulesFile), 0755)
+	if err != nil {
+		return fmt.Errorf("Failed to create the directory of %s : %s", portForwardRulesFile, err.Error())
+	}
+	err = ioutil.WriteFile(portForwardRulesFile, []byte(ruleset), 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to save the port forwards : %s", err.Error())
+	}
+
+	if _, err = os.Stat(portForwardUnitFile); err == nil {
+		return nil
+	}
+	err = ioutil.WriteFile(portForwardUnitFile, []byte(portForwardUnit), 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to install the unit restoring the port forwards : %s", err.Error())
+	}
+	output, err := exec.Command("bash", "-c", "systemctl daemon-reload && systemctl enable "+filepath.Base(portForwardUnitFile)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Failed to enable the unit restoring the port forwards : %s : %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// getPortForwardRuleHandles returns the handles of the rules of chain commented with id
+func getPortForwardRuleHandles(chain string, id string) ([]string, error) {
+	output, err := runNft("-a", "list", "chain", "ip", portForwardTable, chain)
+	if err != nil {
+		return nil, err
+	}
+	handles := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, `comment "`+id+`"`) {
+			continue
+		}
+		if match := nftHandleRegexp.FindStringSubmatch(line); match != nil {
+			handles = append(handles, match[1])
+		}
+	}
+	return handles, nil
+}
+
+// ForwardHypervisorPort forwards the port forward.PublicPort of the hypervisor to the port forward.PrivatePort of the
+// host forward.HostIP of the network identified by networkID
+// libvirt rejects the new connections entering isolated and nat networks, the network has to be in route or open mode
+// The rules are saved to be restored when the hypervisor boots
+func (client *Client) ForwardHypervisorPort(networkID string, forward *propsv1.NetworkPortForward) error {
+	if forward.Mode != PortForwardMode.HYPERVISOR {
+		return fmt.Errorf("Only the ports of the hypervisor are forwarded by the driver")
+	}
+	if forward.Protocol != RuleProtocol.TCP && forward.Protocol != RuleProtocol.UDP {
+		return fmt.Errorf("Only tcp and udp ports can be forwarded")
+	}
+	network, err := client.GetNetwork(networkID)
+	if err != nil {
+		return err
+	}
+	if network.Mode != NetworkMode.ROUTE && network.Mode != NetworkMode.OPEN {
+		return fmt.Errorf("Network '%s' is in %s mode, the hypervisor can only forward ports to route or open networks", network.Name, network.Mode.String())
+	}
+
+	err = ensurePortForwardTable()
+	if err != nil {
+		return err
+	}
+	handles, err := getPortForwardRuleHandles("prerouting", forward.ID)
+	if err != nil {
+		return err
+	}
+	if len(handles) != 0 {
+		return fmt.Errorf("Port %d/%s of the hypervisor is already forwarded", forward.PublicPort, strings.ToLower(forward.Protocol.String()))
+	}
+
+	protocol := strings.ToLower(forward.Protocol.String())
+	comment := `"` + forward.ID + `"`
+	// Only the traffic addressed to the hypervisor itself is forwarded, not the one routed through it
+	_, err = runNft("add", "rule", "ip", portForwardTable, "prerouting", "fib", "daddr", "type", "local",
+		protocol, "dport", strconv.Itoa(forward.PublicPort),
+		"dnat", "to", forward.HostIP+":"+strconv.Itoa(forward.PrivatePort), "comment", comment)
+	if err != nil {
+		return err
+	}
+	_, err = runNft("add", "rule", "ip", portForwardTable, "forward",
+		"ip", "daddr", forward.HostIP, protocol, "dport", strconv.Itoa(forward.PrivatePort),
+		"ct", "state", "new", "accept", "comment", comment)
+	if err != nil {
+		_ = client.RemoveHypervisorPortForward(forward)
+		return err
+	}
+	return savePortForwardTable()
+}
+
+// RemoveHypervisorPortForward removes the rules of a port forward made by ForwardHypervisorPort, if any, from the
+// running table and from the one restored at boot
+func (client *Client) RemoveHypervisorPortForward(forward *propsv1.NetworkPortForward) error {
+	if _, err := runNft("list", "table", "ip", portForwardTable); err != nil {
+		// No port has ever been forwarded since the start of the hypervisor
+		return nil
+	}
+
+	for _, chain := range []string{"prerouting", "forward"} {
+		handles, err := getPortForwardRuleHandles(chain, forward.ID)
+		if err != nil {
+			return err
+		}
+		for _, handle := range handles {
+			_, err = runNft("delete", "rule", "ip", portForwardTable, chain, "handle", handle)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return savePortForwardTable()
+}
@@ -0,0 +1,545 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strings"
+
+	libvirt "github.com/libvirt/libvirt-go"
+	libvirtxml "github.com/libvirt/libvirt-go-xml"
+
+	"github.com/CS-SI/LocalDriver/metadata"
+	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	"github.com/CS-SI/LocalDriver/model/enums/RuleDirection"
+	"github.com/CS-SI/LocalDriver/model/enums/RuleProtocol"
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+)
+
+const (
+	// securityGroupFilterPrefix prefixes the name of the nwfilter rendering a security group
+	securityGroupFilterPrefix = "secgroup-"
+	// hostFilterPrefix prefixes the name of the nwfilter referenced by the interfaces of a host, gathering its security groups
+	hostFilterPrefix = "secgroups-"
+
+	// Priorities of the nwfilter rules, the lowest are evaluated first
+	establishedRulePriority = -500
+	serviceRulePriority     = -400
+	groupRulePriority       = 0
+	defaultRulePriority     = 500
+	dropRulePriority        = 1000
+)
+
+// nwfilterDescription is the part of the xml description of a nwfilter needed to manage the security groups
+type nwfilterDescription struct {
+	XMLName xml.Name `xml:"filter"`
+	Name    string   `xml:"name,attr"`
+	UUID    string   `xml:"uuid"`
+	Refs    []struct {
+		Filter string `xml:"filter,attr"`
+	} `xml:"filterref"`
+	Rules []struct {
+		Direction string `xml:"direction,attr"`
+	} `xml:"rule"`
+}
+
+// getNWFilterDescription returns the xml description of filter
+func getNWFilterDescription(filter *libvirt.NWFilter) (*nwfilterDescription, error) {
+	filterXML, err := filter.GetXMLDesc(0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed get xml description of a nwfilter : %s", err.Error())
+	}
+	filterDescription := &nwfilterDescription{}
+	err = xml.Unmarshal([]byte(filterXML), filterDescription)
+	if err != nil {
+		return nil, fmt.Errorf("Failed unmarshall the nwfilter description : %s", err.Error())
+	}
+	return filterDescription, nil
+}
+
+// ruleProtocolElement returns the name of the nwfilter element matching protocol
+func ruleProtocolElement(protocol RuleProtocol.Enum, ipv6 bool) string {
+	element := strings.ToLower(protocol.String())
+	if ipv6 {
+		if protocol == RuleProtocol.ICMP {
+			return "icmpv6"
+		}
+		return element + "-ipv6"
+	}
+	return element
+}
+
+// securityGroupRuleXML renders rule as nwfilter rules, one for each of the addresses (CIDR or IP) it allows
+func securityGroupRuleXML(rule *model.SecurityGroupRule, addresses []string) (string, error) {
+	direction := "in"
+	addressAttribute := "srcip"
+	if rule.Direction == RuleDirection.EGRESS {
+		direction = "out"
+		addressAttribute = "dstip"
+	}
+
+	portsMatch := ""
+	if rule.PortFrom > 0 && (rule.Protocol == RuleProtocol.TCP || rule.Protocol == RuleProtocol.UDP) {
+		portTo := rule.PortTo
+		if portTo == 0 {
+			portTo = rule.PortFrom
+		}
+		portsMatch = fmt.Sprintf(` dstportstart="%d" dstportend="%d"`, rule.PortFrom, portTo)
+	}
+
+	rulesXML := ""
+	for _, address := range addresses {
+		var ip net.IP
+		var prefix int
+		_, ipNet, err := net.ParseCIDR(address)
+		if err == nil {
+			ip = ipNet.IP
+			prefix, _ = ipNet.Mask.Size()
+		} else {
+			ip = net.ParseIP(address)
+			if ip == nil {
+				return "", fmt.Errorf("Invalid address '%s'", address)
+			}
+			prefix = 128
+			if ip.To4() != nil {
+				prefix = 32
+			}
+		}
+		ipv6 := ip.To4() == nil
+
+		addressMatch := ""
+		if prefix > 0 {
+			addressMatch = fmt.Sprintf(` %saddr="%s" %smask="%d"`, addressAttribute, ip.String(), addressAttribute, prefix)
+		}
+		rulesXML += fmt.Sprintf(`
+	<rule action="accept" direction="%s" priority="%d">
+		<%s state="NEW"%s%s/>
+	</rule>`, direction, groupRulePriority, ruleProtocolElement(rule.Protocol, ipv6), addressMatch, portsMatch)
+	}
+	return rulesXML, nil
+}
+
+// getSecurityGroupRuleAddresses returns the addresses allowed by rule : its CIDR, or the cached addresses of the hosts of its source group
+func (client *Client) getSecurityGroupRuleAddresses(rule *model.SecurityGroupRule) ([]string, error) {
+	if rule.SourceGroupID == "" {
+		if rule.CIDR == "" {
+			return []string{"0.0.0.0/0", "::/0"}, nil
+		}
+		return []string{rule.CIDR}, nil
+	}
+
+	mGroup, err := metadata.LoadSecurityGroup(client, rule.SourceGroupID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load source security group '%s' : %s", rule.SourceGroupID, err.Error())
+	}
+	addresses := []string{}
+	for hostID := range mGroup.Get().Hosts {
+		mHost, err := metadata.LoadHostByID(client, hostID)
+		if err != nil || mHost == nil {
+			continue
+		}
+		hostNetworkV1 := propsv1.NewHostNetwork()
+		mHost.Get().Properties.Get(HostProperty.NetworkV1, hostNetworkV1)
+		for _, ip := range hostNetworkV1.IPv4Addresses {
+			if ip != "" {
+				addresses = append(addresses, ip)
+			}
+		}
+		for _, ip := range hostNetworkV1.IPv6Addresses {
+			if ip != "" {
+				addresses = append(addresses, ip)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// securityGroupFilterXML renders group as a nwfilter
+func (client *Client) securityGroupFilterXML(group *model.SecurityGroup) (string, error) {
+	rulesXML := ""
+	for _, rule := range group.Rules {
+		addresses, err := client.getSecurityGroupRuleAddresses(rule)
+		if err != nil {
+			return "", err
+		}
+		ruleXML, err := securityGroupRuleXML(rule, addresses)
+		if err != nil {
+			return "", err
+		}
+		rulesXML += ruleXML
+	}
+
+	uuidXML := ""
+	if group.ID != "" {
+		uuidXML = `
+	<uuid>` + group.ID + `</uuid>`
+	}
+	return `<filter name="` + securityGroupFilterPrefix + group.Name + `" chain="root">` + uuidXML + rulesXML + `
+</filter>`, nil
+}
+
+// hostFilterXML renders the nwfilter gathering the security groups filters of a host
+// The established connections, DHCP and ICMPv6 (neighbor discovery) are always allowed, any other traffic has to be
+// allowed by a security group, except the egress traffic when none of the groups has an EGRESS rule
+func hostFilterXML(name string, uuid string, groupFilters []string, egressRestricted bool) string {
+	filterXML := `<filter name="` + name + `" chain="root">`
+	if uuid != "" {
+		filterXML += `
+	<uuid>` + uuid + `</uuid>`
+	}
+	filterXML += fmt.Sprintf(`
+	<rule action="accept" direction="inout" priority="%d">
+		<all state="ESTABLISHED,RELATED"/>
+	</rule>
+	<rule action="accept" direction="inout" priority="%d">
+		<all-ipv6 state="ESTABLISHED,RELATED"/>
+	</rule>
+	<rule action="accept" direction="out" priority="%d">
+		<udp srcportstart="68" dstportstart="67"/>
+	</rule>
+	<rule action="accept" direction="in" priority="%d">
+		<udp srcportstart="67" dstportstart="68"/>
+	</rule>
+	<rule action="accept" direction="inout" priority="%d">
+		<icmpv6/>
+	</rule>`, establishedRulePriority, establishedRulePriority, serviceRulePriority, serviceRulePriority, serviceRulePriority)
+	for _, groupFilter := range groupFilters {
+		filterXML += `
+	<filterref filter="` + groupFilter + `"/>`
+	}
+	if !egressRestricted {
+		filterXML += fmt.Sprintf(`
+	<rule action="accept" direction="out" priority="%d">
+		<all state="NEW"/>
+	</rule>
+	<rule action="accept" direction="out" priority="%d">
+		<all-ipv6 state="NEW"/>
+	</rule>`, defaultRulePriority, defaultRulePriority)
+	}
+	filterXML += fmt.Sprintf(`
+	<rule action="drop" direction="inout" priority="%d">
+		<all/>
+	</rule>
+	<rule action="drop" direction="inout" priority="%d">
+		<all-ipv6/>
+	</rule>
+</filter>`, dropRulePriority, dropRulePriority)
+	return filterXML
+}
+
+// getHostFilterRefs returns the names of the security groups filters referenced by the filter of the host domainName
+func (client *Client) getHostFilterRefs(domainName string) ([]string, error) {
+	filter, err := client.LibvirtService.LookupNWFilterByName(hostFilterPrefix + domainName)
+	if err != nil {
+		// The host has no security group
+		return []string{}, nil
+	}
+	defer filter.Free()
+
+	filterDescription, err := getNWFilterDescription(filter)
+	if err != nil {
+		return nil, err
+	}
+	refs := []string{}
+	for _, ref := range filterDescription.Refs {
+		refs = append(refs, ref.Filter)
+	}
+	return refs, nil
+}
+
+// setDomainFilter references the nwfilter named filterName from the interfaces of domain plugged on a libvirt network
+// An empty filterName removes the reference
+func setDomainFilter(domain *libvirt.Domain, filterName string) error {
+	domainDescription, err := getDomainDescription(domain)
+	if err != nil {
+		return err
+	}
+	flags, err := domainModificationFlags(domain)
+	if err != nil {
+		return err
+	}
+
+	for _, iface := range domainDescription.Devices.Interfaces {
+		if iface.Source == nil || iface.Source.Network == nil {
+			continue
+		}
+		if filterName == "" {
+			if iface.FilterRef == nil {
+				continue
+			}
+			iface.FilterRef = nil
+		} else {
+			if iface.FilterRef != nil && iface.FilterRef.Filter == filterName {
+				continue
+			}
+			iface.FilterRef = &libvirtxml.DomainInterfaceFilterRef{Filter: filterName}
+		}
+		ifaceXML, err := iface.Marshal()
+		if err != nil {
+			return fmt.Errorf("Failed to marshal the interface : %s", err.Error())
+		}
+		err = domain.UpdateDeviceFlags(ifaceXML, flags)
+		if err != nil {
+			return fmt.Errorf("Failed to update the interface : %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// applySecurityGroups (re)defines the filter of the host domain from the security groups filters groupFilters and
+// references it from the interfaces of the domain, without group the filter is removed
+func (client *Client) applySecurityGroups(domain *libvirt.Domain, groupFilters []string) error {
+	domainName, err := domain.GetName()
+	if err != nil {
+		return fmt.Errorf("Failed to get domain name : %s", err.Error())
+	}
+	filterName := hostFilterPrefix + domainName
+
+	filter, err := client.LibvirtService.LookupNWFilterByName(filterName)
+	if err != nil {
+		filter = nil
+	} else {
+		defer filter.Free()
+	}
+
+	if len(groupFilters) == 0 {
+		err = setDomainFilter(domain, "")
+		if err != nil {
+			return err
+		}
+		if filter != nil {
+			err = filter.Undefine()
+			if err != nil {
+				return fmt.Errorf("Failed to undefine the nwfilter '%s' : %s", filterName, err.Error())
+			}
+		}
+		return nil
+	}
+
+	egressRestricted := false
+	for _, groupFilter := range groupFilters {
+		libvirtGroupFilter, err := client.LibvirtService.LookupNWFilterByName(groupFilter)
+		if err != nil {
+			return fmt.Errorf("Failed to fetch nwfilter '%s' : %s", groupFilter, err.Error())
+		}
+		groupDescription, err := getNWFilterDescription(libvirtGroupFilter)
+		libvirtGroupFilter.Free()
+		if err != nil {
+			return err
+		}
+		for _, rule := range groupDescription.Rules {
+			if rule.Direction == "out" {
+				egressRestricted = true
+			}
+		}
+	}
+
+	uuid := ""
+	if filter != nil {
+		uuid, err = filter.GetUUIDString()
+		if err != nil {
+			return fmt.Errorf("Failed to get the uuid of nwfilter '%s' : %s", filterName, err.Error())
+		}
+	}
+	newFilter, err := client.LibvirtService.NWFilterDefineXML(hostFilterXML(filterName, uuid, groupFilters, egressRestricted))
+	if err != nil {
+		return fmt.Errorf("Failed to define the nwfilter '%s' : %s", filterName, err.Error())
+	}
+	newFilter.Free()
+
+	return setDomainFilter(domain, filterName)
+}
+
+// removeHostFilter undefines the filter of the undefined host domainName, if any
+func (client *Client) removeHostFilter(domainName string) error {
+	filter, err := client.LibvirtService.LookupNWFilterByName(hostFilterPrefix + domainName)
+	if err != nil {
+		return nil
+	}
+	defer filter.Free()
+
+	err = filter.Undefine()
+	if err != nil {
+		return fmt.Errorf("Failed to undefine the nwfilter : %s", err.Error())
+	}
+	return nil
+}
+
+// getSecurityGroupFilter returns the nwfilter rendering the security group identified by id
+func (client *Client) getSecurityGroupFilter(id string) (*libvirt.NWFilter, error) {
+	filter, err := client.LibvirtService.LookupNWFilterByUUIDString(id)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch nwfilter of security group '%s' : %s", id, err.Error())
+	}
+	return filter, nil
+}
+
+// CreateSecurityGroup creates a security group without rule, dropping all the ingress traffic
+func (client *Client) CreateSecurityGroup(request model.SecurityGroupRequest) (*model.SecurityGroup, error) {
+	if request.Name == "" {
+		return nil, fmt.Errorf("A security group needs a name")
+	}
+	filter, err := client.LibvirtService.LookupNWFilterByName(securityGroupFilterPrefix + request.Name)
+	if err == nil {
+		filter.Free()
+		return nil, fmt.Errorf("Security group '%s' already exists", request.Name)
+	}
+
+	group := model.NewSecurityGroup()
+	group.Name = request.Name
+	group.Description = request.Description
+
+	filterXML, err := client.securityGroupFilterXML(group)
+	if err != nil {
+		return nil, err
+	}
+	filter, err = client.LibvirtService.NWFilterDefineXML(filterXML)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to define the nwfilter : %s", err.Error())
+	}
+	defer filter.Free()
+
+	group.ID, err = filter.GetUUIDString()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the uuid of the nwfilter : %s", err.Error())
+	}
+	return group, nil
+}
+
+// UpdateSecurityGroup renders again the rules of group, the filters of the hosts it is applied to being updated on the fly
+func (client *Client) UpdateSecurityGroup(group *model.SecurityGroup) error {
+	filter, err := client.getSecurityGroupFilter(group.ID)
+	if err != nil {
+		return err
+	}
+	filter.Free()
+
+	filterXML, err := client.securityGroupFilterXML(group)
+	if err != nil {
+		return err
+	}
+	filter, err = client.LibvirtService.NWFilterDefineXML(filterXML)
+	if err != nil {
+		return fmt.Errorf("Failed to define the nwfilter : %s", err.Error())
+	}
+	filter.Free()
+
+	// Adding the first or removing the last EGRESS rule changes the default egress policy of the hosts
+	for hostID := range group.Hosts {
+		domain, err := client.getDomainFromRef(hostID)
+		if err != nil {
+			return err
+		}
+		domainName, err := domain.GetName()
+		if err != nil {
+			return fmt.Errorf("Failed to get domain name : %s", err.Error())
+		}
+		refs, err := client.getHostFilterRefs(domainName)
+		if err != nil {
+			return err
+		}
+		err = client.applySecurityGroups(domain, refs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSecurityGroup deletes the security group identified by id, it must not be applied to any host
+func (client *Client) DeleteSecurityGroup(id string) error {
+	filter, err := client.getSecurityGroupFilter(id)
+	if err != nil {
+		return err
+	}
+	defer filter.Free()
+
+	err = filter.Undefine()
+	if err != nil {
+		return fmt.Errorf("Failed to undefine the nwfilter : %s", err.Error())
+	}
+	return nil
+}
+
+// AttachSecurityGroup applies the security group identified by groupID to the host identified by hostID
+func (client *Client) AttachSecurityGroup(hostID string, groupID string) error {
+	domain, err := client.getDomainFromRef(hostID)
+	if err != nil {
+		return err
+	}
+	domainName, err := domain.GetName()
+	if err != nil {
+		return fmt.Errorf("Failed to get domain name : %s", err.Error())
+	}
+	filter, err := client.getSecurityGroupFilter(groupID)
+	if err != nil {
+		return err
+	}
+	groupFilter, err := filter.GetName()
+	filter.Free()
+	if err != nil {
+		return fmt.Errorf("Failed to get the name of the nwfilter : %s", err.Error())
+	}
+
+	refs, err := client.getHostFilterRefs(domainName)
+	if err != nil {
+		return err
+	}
+	if contains(refs, groupFilter) {
+		return nil
+	}
+	return client.applySecurityGroups(domain, append(refs, groupFilter))
+}
+
+// DetachSecurityGroup stops applying the security group identified by groupID to the host identified by hostID
+func (client *Client) DetachSecurityGroup(hostID string, groupID string) error {
+	domain, err := client.getDomainFromRef(hostID)
+	if err != nil {
+		return err
+	}
+	domainName, err := domain.GetName()
+	if err != nil {
+		return fmt.Errorf("Failed to get domain name : %s", err.Error())
+	}
+	filter, err := client.getSecurityGroupFilter(groupID)
+	if err != nil {
+		return err
+	}
+	groupFilter, err := filter.GetName()
+	filter.Free()
+	if err != nil {
+		return fmt.Errorf("Failed to get the name of the nwfilter : %s", err.Error())
+	}
+
+	refs, err := client.getHostFilterRefs(domainName)
+	if err != nil {
+		return err
+	}
+	remainingRefs := []string{}
+	for _, ref := range refs {
+		if ref != groupFilter {
+			remainingRefs = append(remainingRefs, ref)
+		}
+	}
+	if len(remainingRefs) == len(refs) {
+		return nil
+	}
+	return client.applySecurityGroups(domain, remainingRefs)
+}
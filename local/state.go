@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/LocalDriver/model/enums/HostState"
+	libvirt "github.com/libvirt/libvirt-go"
+)
+
+// domainStateNames contains the description of the libvirt domain states
+var domainStateNames = map[libvirt.DomainState]string{
+	libvirt.DOMAIN_NOSTATE:     "no state",
+	libvirt.DOMAIN_RUNNING:     "running",
+	libvirt.DOMAIN_BLOCKED:     "blocked",
+	libvirt.DOMAIN_PAUSED:      "paused",
+	libvirt.DOMAIN_SHUTDOWN:    "shutting down",
+	libvirt.DOMAIN_SHUTOFF:     "shut off",
+	libvirt.DOMAIN_CRASHED:     "crashed",
+	libvirt.DOMAIN_PMSUSPENDED: "pmsuspended",
+}
+
+// domainReasonNames contains the description of the reason codes of each libvirt domain state
+var domainReasonNames = map[libvirt.DomainState]map[int]string{
+	libvirt.DOMAIN_RUNNING: {
+		int(libvirt.DOMAIN_RUNNING_BOOTED):             "booted",
+		int(libvirt.DOMAIN_RUNNING_MIGRATED):           "migrated",
+		int(libvirt.DOMAIN_RUNNING_RESTORED):           "restored",
+		int(libvirt.DOMAIN_RUNNING_FROM_SNAPSHOT):      "from snapshot",
+		int(libvirt.DOMAIN_RUNNING_UNPAUSED):           "unpaused",
+		int(libvirt.DOMAIN_RUNNING_MIGRATION_CANCELED): "migration canceled",
+		int(libvirt.DOMAIN_RUNNING_SAVE_CANCELED):      "save canceled",
+		int(libvirt.DOMAIN_RUNNING_WAKEUP):             "event wakeup",
+		int(libvirt.DOMAIN_RUNNING_CRASHED):            "crashed",
+		int(libvirt.DOMAIN_RUNNING_POSTCOPY):           "post-copy",
+	},
+	libvirt.DOMAIN_PAUSED: {
+		int(libvirt.DOMAIN_PAUSED_USER):            "user",
+		int(libvirt.DOMAIN_PAUSED_MIGRATION):       "migrating",
+		int(libvirt.DOMAIN_PAUSED_SAVE):            "saving",
+		int(libvirt.DOMAIN_PAUSED_DUMP):            "dumping",
+		int(libvirt.DOMAIN_PAUSED_IOERROR):         "I/O error",
+		int(libvirt.DOMAIN_PAUSED_WATCHDOG):        "watchdog",
+		int(libvirt.DOMAIN_PAUSED_FROM_SNAPSHOT):   "from snapshot",
+		int(libvirt.DOMAIN_PAUSED_SHUTTING_DOWN):   "shutting down",
+		int(libvirt.DOMAIN_PAUSED_SNAPSHOT):        "creating snapshot",
+		int(libvirt.DOMAIN_PAUSED_CRASHED):         "crashed",
+		int(libvirt.DOMAIN_PAUSED_STARTING_UP):     "starting up",
+		int(libvirt.DOMAIN_PAUSED_POSTCOPY):        "post-copy",
+		int(libvirt.DOMAIN_PAUSED_POSTCOPY_FAILED): "post-copy failed",
+	},
+	libvirt.DOMAIN_SHUTDOWN: {
+		int(libvirt.DOMAIN_SHUTDOWN_USER): "user",
+	},
+	libvirt.DOMAIN_SHUTOFF: {
+		int(libvirt.DOMAIN_SHUTOFF_SHUTDOWN):      "shutdown",
+		int(libvirt.DOMAIN_SHUTOFF_DESTROYED):     "destroyed",
+		int(libvirt.DOMAIN_SHUTOFF_CRASHED):       "crashed",
+		int(libvirt.DOMAIN_SHUTOFF_MIGRATED):      "migrated",
+		int(libvirt.DOMAIN_SHUTOFF_SAVED):         "saved",
+		int(libvirt.DOMAIN_SHUTOFF_FAILED):        "failed",
+		int(libvirt.DOMAIN_SHUTOFF_FROM_SNAPSHOT): "from snapshot",
+	},
+	libvirt.DOMAIN_CRASHED: {
+		int(libvirt.DOMAIN_CRASHED_PANICKED): "panicked",
+	},
+}
+
+// stateConvert converts a libvirt.DomainState and its reason code to a HostState.Enum, and describes them (ex: "paused (I/O error)")
+func stateConvert(stateLibvirt libvirt.DomainState, reason int) (HostState.Enum, string) {
+	var state HostState.Enum
+	switch stateLibvirt {
+	case libvirt.DOMAIN_RUNNING, libvirt.DOMAIN_BLOCKED:
+		state = HostState.STARTED
+	case libvirt.DOMAIN_PAUSED:
+		state = HostState.PAUSED
+		if reason == int(libvirt.DOMAIN_PAUSED_STARTING_UP) {
+			state = HostState.STARTING
+		}
+	case libvirt.DOMAIN_SHUTDOWN:
+		state = HostState.STOPPING
+	case libvirt.DOMAIN_SHUTOFF:
+		state = HostState.STOPPED
+		if reason == int(libvirt.DOMAIN_SHUTOFF_SAVED) {
+			state = HostState.SUSPENDED
+		}
+	case libvirt.DOMAIN_CRASHED:
+		state = HostState.CRASHED
+	case libvirt.DOMAIN_PMSUSPENDED:
+		state = HostState.SUSPENDED
+	default:
+		state = HostState.ERROR
+	}
+
+	stateName, ok := domainStateNames[stateLibvirt]
+	if !ok {
+		stateName = fmt.Sprintf("state %d", stateLibvirt)
+	}
+	reasonName, ok := domainReasonNames[stateLibvirt][reason]
+	if !ok {
+		reasonName = "unknown"
+	}
+
+	return state, fmt.Sprintf("%s (%s)", stateName, reasonName)
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/CS-SI/LocalDriver/model"
+	libvirt "github.com/libvirt/libvirt-go"
+)
+
+// domainStatsTypes lists the groups of statistics fetched for the hosts
+const domainStatsTypes = libvirt.DOMAIN_STATS_STATE | libvirt.DOMAIN_STATS_CPU_TOTAL | libvirt.DOMAIN_STATS_BALLOON |
+	libvirt.DOMAIN_STATS_VCPU | libvirt.DOMAIN_STATS_INTERFACE | libvirt.DOMAIN_STATS_BLOCK
+
+// getDomainsStats fetches in one call the statistics of the domains (of every domain if domains is empty)
+func (client *Client) getDomainsStats(domains []*libvirt.Domain) ([]*model.HostStats, error) {
+	domainsStats, err := client.LibvirtService.GetAllDomainStats(domains, domainStatsTypes, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the statistics of the domains : %s", err.Error())
+	}
+	// Each record holds a reference on its domain
+	defer func() {
+		for _, domainStats := range domainsStats {
+			domainStats.Domain.Free()
+		}
+	}()
+
+	now := time.Now()
+	hostsStats := []*model.HostStats{}
+	for _, domainStats := range domainsStats {
+		hostStats := &model.HostStats{Time: now}
+		hostStats.HostID, err = domainStats.Domain.GetUUIDString()
+		if err != nil {
+			return nil, fmt.Errorf(fmt.Sprintf("Failed to fetch id from domain : %s", err.Error()))
+		}
+		hostStats.HostName, err = domainStats.Domain.GetName()
+		if err != nil {
+			return nil, fmt.Errorf(fmt.Sprintf("Failed to fetch name from domain : %s", err.Error()))
+		}
+
+		if domainStats.State != nil && domainStats.State.StateSet {
+			hostStats.Active = domainStats.State.State == libvirt.DOMAIN_RUNNING ||
+				domainStats.State.State == libvirt.DOMAIN_BLOCKED ||
+				domainStats.State.State == libvirt.DOMAIN_PAUSED
+		}
+		if domainStats.Cpu != nil && domainStats.Cpu.TimeSet {
+			hostStats.CPUTime = domainStats.Cpu.Time
+		}
+		// The statistics list every vCPU slot up to the maximum, the hot-pluggable ones are offline
+		for _, vcpu := range domainStats.Vcpu {
+			if vcpu.StateSet && vcpu.State != libvirt.VCPU_OFFLINE {
+				hostStats.VCPUs++
+			}
+		}
+		if domainStats.Balloon != nil {
+			hostStats.MemoryBalloon = domainStats.Balloon.Current
+			hostStats.MemoryMaximum = domainStats.Balloon.Maximum
+			hostStats.MemoryRSS = domainStats.Balloon.Rss
+			hostStats.MemoryUnused = domainStats.Balloon.Unused
+		}
+		for _, block := range domainStats.Block {
+			hostStats.Disks = append(hostStats.Disks, model.HostDiskStats{
+				Name:       block.Name,
+				ReadBytes:  block.RdBytes,
+				ReadOps:    block.RdReqs,
+				WriteBytes: block.WrBytes,
+				WriteOps:   block.WrReqs,
+			})
+		}
+		for _, net := range domainStats.Net {
+			hostStats.Interfaces = append(hostStats.Interfaces, model.HostInterfaceStats{
+				Name:      net.Name,
+				RxBytes:   net.RxBytes,
+				RxPackets: net.RxPkts,
+				RxErrors:  net.RxErrs,
+				RxDrops:   net.RxDrop,
+				TxBytes:   net.TxBytes,
+				TxPackets: net.TxPkts,
+				TxErrors:  net.TxErrs,
+				TxDrops:   net.TxDrop,
+			})
+		}
+
+		hostsStats = append(hostsStats, hostStats)
+	}
+
+	return hostsStats, nil
+}
+
+// GetHostStats returns the runtime statistics of the host identified by id
+func (client *Client) GetHostStats(id string) (*model.HostStats, error) {
+	domain, err := client.getDomainFromRef(id)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("getDomainFromRef failed : %s", err.Error()))
+	}
+
+	hostsStats, err := client.getDomainsStats([]*libvirt.Domain{domain})
+	if err != nil {
+		return nil, err
+	}
+	if len(hostsStats) != 1 {
+		return nil, fmt.Errorf("No statistics returned for host %s", id)
+	}
+
+	return hostsStats[0], nil
+}
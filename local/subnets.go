@@ -0,0 +1,226 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	libvirt "github.com/libvirt/libvirt-go"
+)
+
+// defaultSupernetPool is the IPv4 range the subnets of the networks created without CIDR are taken from, when none is configured
+const defaultSupernetPool = "10.100.0.0/16"
+
+// defaultSubnetPrefix is the length of the prefix of the subnets taken from the supernet pool, when none is configured
+const defaultSubnetPrefix = 24
+
+// usedSubnet is a subnet already in use on the hypervisor
+type usedSubnet struct {
+	subnet *net.IPNet
+	// owner describes what uses the subnet (network, interface or route)
+	owner string
+}
+
+// overlaps tells if the subnets a and b share at least one address
+func overlaps(a *net.IPNet, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// getNetworksSubnets returns the subnets of the libvirt networks
+func getNetworksSubnets(libvirtService *libvirt.Connect) ([]usedSubnet, error) {
+	libvirtNetworks, err := libvirtService.ListAllNetworks(3)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Error listing networks : %s", err.Error()))
+	}
+
+	used := []usedSubnet{}
+	for _, libvirtNetwork := range libvirtNetworks {
+		networkDescription, err := getNetworkDescription(&libvirtNetwork)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range networkDescription.IPs {
+			prefix, err := getNetworkIPPrefix(ip)
+			if err != nil {
+				return nil, err
+			}
+			_, ipNet, err := net.ParseCIDR(fmt.Sprintf("%s/%d", ip.Address, prefix))
+			if err != nil {
+				continue
+			}
+			used = append(used, usedSubnet{subnet: ipNet, owner: "network " + networkDescription.Name})
+		}
+	}
+	return used, nil
+}
+
+// getInterfacesSubnets returns the subnets of the addresses of the interfaces of the hypervisor
+func getInterfacesSubnets() ([]usedSubnet, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list the interfaces of the hypervisor : %s", err.Error())
+	}
+
+	used := []usedSubnet{}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list the addresses of %s : %s", iface.Name, err.Error())
+		}
+		for _, addr := range addrs {
+			ip, ipNet, err := net.ParseCIDR(addr.String())
+			if err != nil || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			used = append(used, usedSubnet{subnet: ipNet, owner: "interface " + iface.Name})
+		}
+	}
+	return used, nil
+}
+
+// getRoutesSubnets returns the destinations of the IPv4 and IPv6 routes of the hypervisor, except the default routes
+func getRoutesSubnets() ([]usedSubnet, error) {
+	used := []usedSubnet{}
+
+	// Iface Destination Gateway Flags RefCnt Use Metric Mask ..., addresses in little endian hexadecimal
+	err := readProcFile("/proc/net/route", func(fields []string) {
+		if len(fields) < 8 || fields[0] == "Iface" {
+			return
+		}
+		destination, err1 := strconv.ParseUint(fields[1], 16, 32)
+		mask, err2 := strconv.ParseUint(fields[7], 16, 32)
+		if err1 != nil || err2 != nil || mask == 0 {
+			return
+		}
+		ipNet := &net.IPNet{
+			IP:   net.IPv4(byte(destination), byte(destination>>8), byte(destination>>16), byte(destination>>24)).To4(),
+			Mask: net.IPv4Mask(byte(mask), byte(mask>>8), byte(mask>>16), byte(mask>>24)),
+		}
+		used = append(used, usedSubnet{subnet: ipNet, owner: "route via " + fields[0]})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Destination PrefixLength Source SourcePrefixLength NextHop Metric RefCnt Use Flags Iface
+	err = readProcFile("/proc/net/ipv6_route", func(fields []string) {
+		if len(fields) < 10 {
+			return
+		}
+		destination, err1 := hex.DecodeString(fields[0])
+		prefix, err2 := strconv.ParseUint(fields[1], 16, 8)
+		if err1 != nil || err2 != nil || len(destination) != net.IPv6len || prefix == 0 {
+			return
+		}
+		ip := net.IP(destination)
+		if ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+			return
+		}
+		ipNet := &net.IPNet{IP: ip, Mask: net.CIDRMask(int(prefix), 8*net.IPv6len)}
+		used = append(used, usedSubnet{subnet: ipNet, owner: "route via " + fields[9]})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return used, nil
+}
+
+// readProcFile calls parse with the fields of each line of the file path, a missing file is ignored
+func readProcFile(path string, parse func(fields []string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("Failed to read %s : %s", path, err.Error())
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parse(strings.Fields(scanner.Text()))
+	}
+	return scanner.Err()
+}
+
+// getUsedSubnets returns the subnets used by the libvirt networks, the interfaces and the routes of the hypervisor
+func (client *Client) getUsedSubnets() ([]usedSubnet, error) {
+	used, err := getNetworksSubnets(client.LibvirtService)
+	if err != nil {
+		return nil, err
+	}
+	interfacesSubnets, err := getInterfacesSubnets()
+	if err != nil {
+		return nil, err
+	}
+	routesSubnets, err := getRoutesSubnets()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append(used, interfacesSubnets...), routesSubnets...), nil
+}
+
+// checkSubnetOverlap returns an error if the subnet cidr overlaps one of the used subnets
+func checkSubnetOverlap(cidr string, used []usedSubnet) error {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("Failed to parse cidr : %s", err.Error())
+	}
+	for _, u := range used {
+		if overlaps(ipNet, u.subnet) {
+			return fmt.Errorf("%s overlaps %s used by the %s", cidr, u.subnet.String(), u.owner)
+		}
+	}
+	return nil
+}
+
+// allocateSubnet returns the first subnet of length prefix of the IPv4 pool which overlaps none of the used subnets
+func allocateSubnet(pool string, prefix int, used []usedSubnet) (string, error) {
+	_, poolNet, err := net.ParseCIDR(pool)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse the supernet pool : %s", err.Error())
+	}
+	poolPrefix, bits := poolNet.Mask.Size()
+	if bits != 32 {
+		return "", fmt.Errorf("The supernet pool %s must be an IPv4 range", pool)
+	}
+	if prefix < poolPrefix || prefix > 30 {
+		return "", fmt.Errorf("The subnets of the supernet pool %s can't have a /%d prefix", pool, prefix)
+	}
+
+	count := int64(1) << uint(prefix-poolPrefix)
+	size := int64(1) << uint(bits-prefix)
+	for i := int64(0); i < count; i++ {
+		candidate := &net.IPNet{
+			IP:   offsetIP(poolNet.IP, i*size),
+			Mask: net.CIDRMask(prefix, bits),
+		}
+		if checkSubnetOverlap(candidate.String(), used) == nil {
+			return candidate.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("No free /%d subnet left in the supernet pool %s", prefix, pool)
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package local
+
+import (
+	"net"
+	"testing"
+)
+
+// usedSubnets returns the used subnets made of cidrs
+func usedSubnets(t *testing.T, cidrs ...string) []usedSubnet {
+	used := []usedSubnet{}
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("Failed to parse %s : %s", cidr, err.Error())
+		}
+		used = append(used, usedSubnet{subnet: ipNet, owner: "test"})
+	}
+	return used
+}
+
+func TestCheckSubnetOverlap(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		used    []string
+		wantErr bool
+	}{
+		{cidr: "192.168.1.0/24", used: nil},
+		{cidr: "192.168.1.0/24", used: []string{"192.168.2.0/24", "10.0.0.0/8"}},
+		{cidr: "192.168.1.0/24", used: []string{"192.168.1.0/24"}, wantErr: true},
+		{cidr: "192.168.1.0/24", used: []string{"192.168.0.0/16"}, wantErr: true},
+		{cidr: "192.168.1.0/24", used: []string{"192.168.1.128/25"}, wantErr: true},
+		{cidr: "192.168.0.0/23", used: []string{"192.168.1.0/24"}, wantErr: true},
+		{cidr: "fd00:1::/64", used: []string{"fd00::/64"}},
+		{cidr: "fd00:0:0:1::/64", used: []string{"fd00::/48"}, wantErr: true},
+		{cidr: "192.168.1.0", used: nil, wantErr: true},
+	}
+	for _, test := range tests {
+		err := checkSubnetOverlap(test.cidr, usedSubnets(t, test.used...))
+		if test.wantErr && err == nil {
+			t.Errorf("checkSubnetOverlap(%s, %v) succeeded, want an error", test.cidr, test.used)
+		}
+		if !test.wantErr && err != nil {
+			t.Errorf("checkSubnetOverlap(%s, %v) failed : %s", test.cidr, test.used, err.Error())
+		}
+	}
+}
+
+func TestAllocateSubnet(t *testing.T) {
+	tests := []struct {
+		pool    string
+		prefix  int
+		used    []string
+		want    string
+		wantErr bool
+	}{
+		{pool: "10.100.0.0/16", prefix: 24, want: "10.100.0.0/24"},
+		{pool: "10.100.0.0/16", prefix: 24, used: []string{"10.100.0.0/24"}, want: "10.100.1.0/24"},
+		{pool: "10.100.0.0/16", prefix: 24, used: []string{"10.100.0.0/23", "10.100.2.128/25"}, want: "10.100.3.0/24"},
+		{pool: "10.100.0.0/16", prefix: 24, used: []string{"192.168.0.0/16"}, want: "10.100.0.0/24"},
+		{pool: "10.100.0.0/16", prefix: 16, want: "10.100.0.0/16"},
+		{pool: "10.100.0.0/16", prefix: 16, used: []string{"10.100.42.0/24"}, wantErr: true},
+		{pool: "10.100.0.0/16", prefix: 24, used: []string{"10.0.0.0/8"}, wantErr: true},
+		{pool: "10.100.0.0/30", prefix: 30, want: "10.100.0.0/30"},
+		{pool: "10.100.0.0/16", prefix: 31, wantErr: true},
+		{pool: "10.100.0.0/16", prefix: 15, wantErr: true},
+		{pool: "fd00::/48", prefix: 64, wantErr: true},
+		{pool: "10.100.0.0", prefix: 24, wantErr: true},
+	}
+	for _, test := range tests {
+		subnet, err := allocateSubnet(test.pool, test.prefix, usedSubnets(t, test.used...))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("allocateSubnet(%s, %d, %v) returned %s, want an error", test.pool, test.prefix, test.used, subnet)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("allocateSubnet(%s, %d, %v) failed : %s", test.pool, test.prefix, test.used, err.Error())
+			continue
+		}
+		if subnet != test.want {
+			t.Errorf("allocateSubnet(%s, %d, %v) = %s, want %s", test.pool, test.prefix, test.used, subnet, test.want)
+		}
+	}
+}
@@ -17,20 +17,31 @@
 package local
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"hash/fnv"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/VolumeProperty"
+	propsv2 "github.com/CS-SI/LocalDriver/model/properties/v2"
 	libvirt "github.com/libvirt/libvirt-go"
 	libvirtxml "github.com/libvirt/libvirt-go-xml"
 )
 
 //-------------Utils----------------------------------------------------------------------------------------------------
 
+// escapeXML escapes s to be placed in the text of an xml element or in an xml attribute
+func escapeXML(s string) string {
+	var buffer bytes.Buffer
+	_ = xml.EscapeText(&buffer, []byte(s))
+	return buffer.String()
+}
+
 func hash(s string) string {
 	h := fnv.New32a()
 	h.Write([]byte(s))
@@ -109,6 +120,72 @@ func getVolumeFromLibvirtVolume(libvirtVolume *libvirt.StorageVol) (*model.Volum
 	return volume, nil
 }
 
+func getVolumeDescription(libvirtVolume *libvirt.StorageVol) (*libvirtxml.StorageVolume, error) {
+	volumeXML, err := libvirtVolume.GetXMLDesc(0)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed get xml description of the volume : %s", err.Error()))
+	}
+	volumeDescription := &libvirtxml.StorageVolume{}
+	err = xml.Unmarshal([]byte(volumeXML), volumeDescription)
+	if err != nil {
+		return nil, fmt.Errorf(fmt.Sprintf("Failed unmarshall the volume description : %s", err.Error()))
+	}
+
+	return volumeDescription, nil
+}
+
+// getDomainUsingVolume returns the domain having a disk backed by the file volumePath, and the target device of this disk
+// returns nil, "", nil if the volume is not used by any domain
+func (client *Client) getDomainUsingVolume(volumePath string) (*libvirt.Domain, string, error) {
+	domains, err := client.LibvirtService.ListAllDomains(libvirt.CONNECT_LIST_DOMAINS_ACTIVE | libvirt.CONNECT_LIST_DOMAINS_INACTIVE)
+	if err != nil {
+		return nil, "", fmt.Errorf(fmt.Sprintf("Error listing domains : %s", err.Error()))
+	}
+	for _, domain := range domains {
+		domainXML, err := domain.GetXMLDesc(0)
+		if err != nil {
+			return nil, "", fmt.Errorf(fmt.Sprintf("Failed get xml description of the domain : %s", err.Error()))
+		}
+		domainDescription := &libvirtxml.Domain{}
+		err = xml.Unmarshal([]byte(domainXML), domainDescription)
+		if err != nil {
+			return nil, "", fmt.Errorf(fmt.Sprintf("Failed unmarshall the domain description : %s", err.Error()))
+		}
+		for _, disk := range domainDescription.Devices.Disks {
+			if disk.Source != nil && disk.Source.File != nil && disk.Source.File.File == volumePath {
+				domain := domain
+				return &domain, disk.Target.Dev, nil
+			}
+		}
+	}
+
+	return nil, "", nil
+}
+
+// setVolumeParent records in the DescriptionV2 property of volume the libvirt volume it has been made from
+func setVolumeParent(volume *model.Volume, parent *libvirt.StorageVol, isSnapshot bool) error {
+	parentName, err := parent.GetName()
+	if err != nil {
+		return fmt.Errorf("Failed to get volume name : %s", err.Error())
+	}
+	parentID, err := getVolumeId(parent)
+	if err != nil {
+		return fmt.Errorf("Failed to hash the volume : %s", err.Error())
+	}
+
+	volumeDescriptionV2 := propsv2.NewVolumeDescription()
+	volumeDescriptionV2.Created = time.Now()
+	volumeDescriptionV2.IsSnapshot = isSnapshot
+	volumeDescriptionV2.ParentID = parentID
+	volumeDescriptionV2.ParentName = parentName
+	err = volume.Properties.Set(VolumeProperty.DescriptionV2, volumeDescriptionV2)
+	if err != nil {
+		return fmt.Errorf("Failed to set volume propertie DescriptionV2 : %s", err.Error())
+	}
+
+	return nil
+}
+
 func getAttachmentFromVolumeAndDomain(volume *libvirt.StorageVol, domain *libvirt.Domain) (*model.VolumeAttachment, error) {
 	attachment := &model.VolumeAttachment{}
 
@@ -210,11 +287,11 @@ func (client *Client) CreateVolume(request model.VolumeRequest) (*model.Volume,
 
 	requestXML := `
 	<volume>
-		<name>` + request.Name + `</name>
+		<name>` + escapeXML(request.Name) + `</name>
 		<allocation>0</allocation>
 		<capacity unit="G">` + strconv.Itoa(request.Size) + `</capacity>
 		<target>
-			<path>` + storagePoolDescription.Target.Path + `</path>
+			<path>` + escapeXML(storagePoolDescription.Target.Path) + `</path>
         </target>
 	</volume>`
 
@@ -287,12 +364,170 @@ func (client *Client) DeleteVolume(ref string) error {
 	return nil
 }
 
+// ResizeVolume grows the volume identified by id to size GB
+// If the volume is attached to a running host, the resize is done by the hypervisor so the guest is notified of the new size
+func (client *Client) ResizeVolume(id string, size int) (*model.Volume, error) {
+	libvirtVolume, err := getLibvirtVolume(id, client.LibvirtService)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the libvirt.Volume from ref : %s", err.Error())
+	}
+	volumeDescription, err := getVolumeDescription(libvirtVolume)
+	if err != nil {
+		return nil, err
+	}
+
+	capacity := uint64(size) * 1024 * 1024 * 1024
+	if capacity <= volumeDescription.Capacity.Value {
+		return nil, fmt.Errorf("Volume %s can only grow, its current size is %d GB", volumeDescription.Name, volumeDescription.Capacity.Value/1024/1024/1024)
+	}
+
+	domain, diskTarget, err := client.getDomainUsingVolume(volumeDescription.Target.Path)
+	if err != nil {
+		return nil, err
+	}
+	active := false
+	if domain != nil {
+		active, err = domain.IsActive()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to know if the domain is active : %s", err.Error())
+		}
+	}
+
+	if active {
+		err = domain.BlockResize(diskTarget, capacity, libvirt.DOMAIN_BLOCK_RESIZE_BYTES)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resize the block device %s of the domain : %s", diskTarget, err.Error())
+		}
+		storagePool, err := libvirtVolume.LookupPoolByVolume()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get the storage pool of volume %s : %s", volumeDescription.Name, err.Error())
+		}
+		err = storagePool.Refresh(0)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to refresh the storage pool of volume %s : %s", volumeDescription.Name, err.Error())
+		}
+	} else {
+		err = libvirtVolume.Resize(capacity, 0)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resize volume %s : %s", volumeDescription.Name, err.Error())
+		}
+	}
+
+	volume, err := getVolumeFromLibvirtVolume(libvirtVolume)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get model.volume from libvirt.Volume : %s", err.Error())
+	}
+
+	return volume, nil
+}
+
+// CreateVolumeSnapshot creates a point-in-time copy of a volume
+// The snapshot is a full copy of the volume made in the same storage pool, clones are then cheap qcow2 overlays on top of it
+func (client *Client) CreateVolumeSnapshot(request model.VolumeSnapshotRequest) (*model.Volume, error) {
+	libvirtVolume, err := getLibvirtVolume(request.VolumeID, client.LibvirtService)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the libvirt.Volume from ref : %s", err.Error())
+	}
+	volumeDescription, err := getVolumeDescription(libvirtVolume)
+	if err != nil {
+		return nil, err
+	}
+	storagePool, err := libvirtVolume.LookupPoolByVolume()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the storage pool of volume %s : %s", volumeDescription.Name, err.Error())
+	}
+
+	format := "raw"
+	if volumeDescription.Target != nil && volumeDescription.Target.Format != nil && volumeDescription.Target.Format.Type != "" {
+		format = volumeDescription.Target.Format.Type
+	}
+
+	requestXML := `
+	<volume>
+		<name>` + escapeXML(request.Name) + `</name>
+		<capacity unit="bytes">` + strconv.FormatUint(volumeDescription.Capacity.Value, 10) + `</capacity>
+		<target>
+			<format type="` + format + `"/>
+		</target>
+	</volume>`
+
+	libvirtSnapshot, err := storagePool.StorageVolCreateXMLFrom(requestXML, libvirtVolume, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to snapshot the volume %s into %s : %s", volumeDescription.Name, request.Name, err.Error())
+	}
+
+	snapshot, err := getVolumeFromLibvirtVolume(libvirtSnapshot)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get model.Volume form libvirt.Volume %s : %s", request.Name, err.Error())
+	}
+
+	err = setVolumeParent(snapshot, libvirtVolume, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// CreateVolumeClone creates a new volume backed by a snapshot
+// The clone is a qcow2 overlay, only the blocks written after the clone are stored in the new volume
+func (client *Client) CreateVolumeClone(request model.VolumeCloneRequest) (*model.Volume, error) {
+	libvirtSnapshot, err := getLibvirtVolume(request.SnapshotID, client.LibvirtService)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the libvirt.Volume from ref : %s", err.Error())
+	}
+	snapshotDescription, err := getVolumeDescription(libvirtSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	storagePool, err := libvirtSnapshot.LookupPoolByVolume()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the storage pool of snapshot %s : %s", snapshotDescription.Name, err.Error())
+	}
+
+	backingFormat := "raw"
+	if snapshotDescription.Target != nil && snapshotDescription.Target.Format != nil && snapshotDescription.Target.Format.Type != "" {
+		backingFormat = snapshotDescription.Target.Format.Type
+	}
+
+	requestXML := `
+	<volume>
+		<name>` + escapeXML(request.Name) + `</name>
+		<allocation>0</allocation>
+		<capacity unit="bytes">` + strconv.FormatUint(snapshotDescription.Capacity.Value, 10) + `</capacity>
+		<target>
+			<format type="qcow2"/>
+		</target>
+		<backingStore>
+			<path>` + escapeXML(snapshotDescription.Target.Path) + `</path>
+			<format type="` + backingFormat + `"/>
+		</backingStore>
+	</volume>`
+
+	libvirtClone, err := storagePool.StorageVolCreateXML(requestXML, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to clone the snapshot %s into %s : %s", snapshotDescription.Name, request.Name, err.Error())
+	}
+
+	clone, err := getVolumeFromLibvirtVolume(libvirtClone)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get model.Volume form libvirt.Volume %s : %s", request.Name, err.Error())
+	}
+
+	err = setVolumeParent(clone, libvirtSnapshot, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return clone, nil
+}
+
 // CreateVolumeAttachment attaches a volume to an host
 // - 'name' of the volume attachment
 // - 'volume' to attach
 // - 'host' on which the volume is attached
 func (client *Client) CreateVolumeAttachment(request model.VolumeAttachmentRequest) (string, error) {
-	_, domain, err := client.getHostAndDomainFromRef(request.HostID)
+	domain, err := client.getDomainFromRef(request.HostID)
 	if err != nil {
 		return "", fmt.Errorf("Failed to get domain from request.HostID : %s", err.Error())
 	}
@@ -325,8 +560,14 @@ func (client *Client) CreateVolumeAttachment(request model.VolumeAttachmentReque
 	newDiskName = strings.Replace(newDiskName, "0", "a", -1)
 	//TODO not working for a name only made of z (ex: 'zzz' will became '1aaa')
 
+	format := "raw"
+	if volumeDescription.Target.Format != nil && volumeDescription.Target.Format.Type != "" {
+		format = volumeDescription.Target.Format.Type
+	}
+
 	requestXML := `
 	<disk type='file' device='disk'>
+		<driver name='qemu' type='` + format + `'/>
 		<source volume='' file='` + volumeDescription.Target.Path + `'/>
 		<target dev='` + newDiskName + `' bus='virtio'/>
 	</disk>`
@@ -346,7 +587,7 @@ func (client *Client) CreateVolumeAttachment(request model.VolumeAttachmentReque
 
 // GetVolumeAttachment returns the volume attachment identified by id
 func (client *Client) GetVolumeAttachment(serverID, id string) (*model.VolumeAttachment, error) {
-	_, domain, err := client.getHostAndDomainFromRef(serverID)
+	domain, err := client.getDomainFromRef(serverID)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get domain from ref : %s", err.Error())
 	}
@@ -366,7 +607,7 @@ func (client *Client) GetVolumeAttachment(serverID, id string) (*model.VolumeAtt
 
 // DeleteVolumeAttachment ...
 func (client *Client) DeleteVolumeAttachment(serverID, id string) error {
-	_, domain, err := client.getHostAndDomainFromRef(serverID)
+	domain, err := client.getDomainFromRef(serverID)
 	if err != nil {
 		return fmt.Errorf("Failed to get domain from ref : %s", err.Error())
 	}
@@ -415,7 +656,7 @@ func (client *Client) ListVolumeAttachments(serverID string) ([]model.VolumeAtta
 	var volumes []*libvirt.StorageVol
 	var volumeAttachments []model.VolumeAttachment
 
-	_, domain, err := client.getHostAndDomainFromRef(serverID)
+	domain, err := client.getDomainFromRef(serverID)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get domain from ref : %s", err.Error())
 	}
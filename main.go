@@ -59,6 +59,12 @@ func main() {
 	app.Commands = append(app.Commands, cliL.VolumeCmd)
 	sort.Sort(cli.CommandsByName(cliL.VolumeCmd.Subcommands))
 
+	app.Commands = append(app.Commands, cliL.SecGroupCmd)
+	sort.Sort(cli.CommandsByName(cliL.SecGroupCmd.Subcommands))
+
+	app.Commands = append(app.Commands, cliL.PortForwardCmd)
+	sort.Sort(cli.CommandsByName(cliL.PortForwardCmd.Subcommands))
+
 	app.Commands = append(app.Commands, cliL.SSHCmd)
 	sort.Sort(cli.CommandsByName(cliL.SSHCmd.Subcommands))
 
@@ -68,6 +74,10 @@ func main() {
 	app.Commands = append(app.Commands, cliL.TemplateCmd)
 	sort.Sort(cli.CommandsByName(cliL.TemplateCmd.Subcommands))
 
+	app.Commands = append(app.Commands, cliL.EventsCmd)
+
+	app.Commands = append(app.Commands, cliL.ExporterCmd)
+
 	// app.Commands = append(app.Commands, cmd.TenantCmd)
 	// sort.Sort(cli.CommandsByName(cmd.TenantCmd.Subcommands))
 
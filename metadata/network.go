@@ -286,6 +286,48 @@ func (m *Network) ListHosts() ([]*model.Host, error) {
 	return list, nil
 }
 
+// AddPortForward records a port forward to a host of the network
+func (m *Network) AddPortForward(forward *propsv1.NetworkPortForward) error {
+	network := m.Get()
+	networkPortForwardsV1 := propsv1.NewNetworkPortForwards()
+	err := network.Properties.Get(NetworkProperty.PortForwardsV1, networkPortForwardsV1)
+	if err != nil {
+		return err
+	}
+	if _, found := networkPortForwardsV1.ByID[forward.ID]; found {
+		return fmt.Errorf("Port forward '%s' already exists", forward.ID)
+	}
+	networkPortForwardsV1.ByID[forward.ID] = forward
+	return network.Properties.Set(NetworkProperty.PortForwardsV1, networkPortForwardsV1)
+}
+
+// RemovePortForward forgets the port forward identified by id
+func (m *Network) RemovePortForward(id string) error {
+	network := m.Get()
+	networkPortForwardsV1 := propsv1.NewNetworkPortForwards()
+	err := network.Properties.Get(NetworkProperty.PortForwardsV1, networkPortForwardsV1)
+	if err != nil {
+		return err
+	}
+	delete(networkPortForwardsV1.ByID, id)
+	return network.Properties.Set(NetworkProperty.PortForwardsV1, networkPortForwardsV1)
+}
+
+// ListPortForwards returns the port forwards to the hosts of the network
+func (m *Network) ListPortForwards() ([]*propsv1.NetworkPortForward, error) {
+	network := m.Get()
+	networkPortForwardsV1 := propsv1.NewNetworkPortForwards()
+	err := network.Properties.Get(NetworkProperty.PortForwardsV1, networkPortForwardsV1)
+	if err != nil {
+		return nil, err
+	}
+	var list []*propsv1.NetworkPortForward
+	for _, forward := range networkPortForwardsV1.ByID {
+		list = append(list, forward)
+	}
+	return list, nil
+}
+
 // Acquire waits until the write lock is available, then locks the metadata
 func (m *Network) Acquire() {
 	m.item.Acquire()
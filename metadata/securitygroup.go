@@ -0,0 +1,212 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metadata
+
+import (
+	"fmt"
+
+	"github.com/CS-SI/LocalDriver/api"
+	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/utils/metadata"
+)
+
+const (
+	// securityGroupsFolderName is the technical name of the container used to store security groups info
+	securityGroupsFolderName = "secgroups"
+)
+
+// SecurityGroup links Object Storage folder and SecurityGroups
+type SecurityGroup struct {
+	item *metadata.Item
+	name *string
+	id   *string
+}
+
+// NewSecurityGroup creates an instance of metadata.SecurityGroup
+func NewSecurityGroup(svc api.ClientAPI) *SecurityGroup {
+	return &SecurityGroup{
+		item: metadata.NewItem(svc, securityGroupsFolderName),
+		name: nil,
+		id:   nil,
+	}
+}
+
+// Carry links a SecurityGroup instance to the Metadata instance
+func (msg *SecurityGroup) Carry(group *model.SecurityGroup) *SecurityGroup {
+	if group == nil {
+		panic("group is nil!")
+	}
+	if group.Hosts == nil {
+		group.Hosts = map[string]string{}
+	}
+	msg.item.Carry(group)
+	msg.name = &group.Name
+	msg.id = &group.ID
+	return msg
+}
+
+// Get returns the SecurityGroup instance linked to metadata
+func (msg *SecurityGroup) Get() *model.SecurityGroup {
+	if msg.item == nil {
+		panic("msg.item is nil!")
+	}
+	if group, ok := msg.item.Get().(*model.SecurityGroup); ok {
+		return group
+	}
+	panic("invalid content in security group metadata")
+}
+
+// Write updates the metadata corresponding to the security group in the Object Storage
+func (msg *SecurityGroup) Write() error {
+	if msg.item == nil {
+		panic("msg.item is nil!")
+	}
+
+	err := msg.item.WriteInto(ByIDFolderName, *msg.id)
+	if err != nil {
+		return err
+	}
+	return msg.item.WriteInto(ByNameFolderName, *msg.name)
+}
+
+// Reload reloads the content of the Object Storage, overriding what is in the metadata instance
+func (msg *SecurityGroup) Reload() error {
+	if msg.item == nil {
+		panic("msg.item is nil!")
+	}
+	found, err := msg.ReadByID(*msg.id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("metadata of security group '%s' vanished", *msg.name)
+	}
+	return nil
+}
+
+// ReadByID reads the metadata of a security group identified by ID from Object Storage
+func (msg *SecurityGroup) ReadByID(id string) (bool, error) {
+	var group model.SecurityGroup
+	found, err := msg.item.ReadFrom(ByIDFolderName, id, func(buf []byte) (model.Serializable, error) {
+		err := (&group).Deserialize(buf)
+		if err != nil {
+			return nil, err
+		}
+		return &group, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, nil
+	}
+
+	msg.Carry(&group)
+	return true, nil
+}
+
+// ReadByName reads the metadata of a security group identified by name
+func (msg *SecurityGroup) ReadByName(name string) (bool, error) {
+	var group model.SecurityGroup
+	found, err := msg.item.ReadFrom(ByNameFolderName, name, func(buf []byte) (model.Serializable, error) {
+		err := (&group).Deserialize(buf)
+		if err != nil {
+			return nil, err
+		}
+		return &group, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, nil
+	}
+
+	msg.Carry(&group)
+	return true, nil
+}
+
+// Delete delete the metadata corresponding to the security group
+func (msg *SecurityGroup) Delete() error {
+	err := msg.item.DeleteFrom(ByIDFolderName, *msg.id)
+	if err != nil {
+		return err
+	}
+	err = msg.item.DeleteFrom(ByNameFolderName, *msg.name)
+	if err != nil {
+		return err
+	}
+	msg.item.Reset()
+	msg.name = nil
+	msg.id = nil
+	return nil
+}
+
+// Browse walks through security group folder and executes a callback for each entries
+func (msg *SecurityGroup) Browse(callback func(*model.SecurityGroup) error) error {
+	return msg.item.BrowseInto(ByIDFolderName, func(buf []byte) error {
+		group := model.SecurityGroup{}
+		err := (&group).Deserialize(buf)
+		if err != nil {
+			return err
+		}
+		return callback(&group)
+	})
+}
+
+// AttachHost links host to the security group
+func (msg *SecurityGroup) AttachHost(host *model.Host) {
+	msg.Get().Hosts[host.ID] = host.Name
+}
+
+// DetachHost unlinks host ID from the security group
+func (msg *SecurityGroup) DetachHost(hostID string) {
+	delete(msg.Get().Hosts, hostID)
+}
+
+// SaveSecurityGroup saves the SecurityGroup definition in Object Storage
+func SaveSecurityGroup(svc api.ClientAPI, group *model.SecurityGroup) error {
+	return NewSecurityGroup(svc).Carry(group).Write()
+}
+
+// RemoveSecurityGroup removes the SecurityGroup definition from Object Storage
+func RemoveSecurityGroup(svc api.ClientAPI, groupID string) error {
+	m, err := LoadSecurityGroup(svc, groupID)
+	if err != nil {
+		return err
+	}
+	return m.Delete()
+}
+
+// LoadSecurityGroup gets the SecurityGroup definition from Object Storage
+func LoadSecurityGroup(svc api.ClientAPI, ref string) (*SecurityGroup, error) {
+	m := NewSecurityGroup(svc)
+	found, err := m.ReadByID(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		found, err = m.ReadByName(ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, model.ResourceNotFoundError("security group", ref)
+	}
+	return m, nil
+}
@@ -0,0 +1,31 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package HostPowerMethod
+
+//go:generate stringer -type=Enum
+
+//Enum represents the way a host has been stopped or rebooted
+type Enum int
+
+const (
+	// NONE when nothing had to be done (ex: host already stopped)
+	NONE Enum = iota
+	// GRACEFUL when the guest has handled the request itself (ACPI or guest agent)
+	GRACEFUL
+	// FORCED when the host has been powered off or reset
+	FORCED
+)
@@ -31,4 +31,10 @@ const (
 	SharesV1 = "6"
 	// MountsV1 contains optional additional info about mounted devices (locally attached or remote filesystem)
 	MountsV1 = "7"
+	// NetworkV2 contains additional info about the network of the host, and how and when the IP addresses have been resolved
+	NetworkV2 = "8"
+	// HibernationV1 contains optional additional info about the state of the host saved on disk
+	HibernationV1 = "9"
+	// SecurityGroupsV1 contains optional additional info about the security groups applied to the host
+	SecurityGroupsV1 = "10"
 )
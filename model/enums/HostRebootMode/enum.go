@@ -0,0 +1,46 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package HostRebootMode
+
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate stringer -type=Enum
+
+//Enum represents the way a host is asked to reboot
+type Enum int
+
+const (
+	// ACPI sends an ACPI power button event to the guest
+	ACPI Enum = iota
+	// AGENT asks the qemu guest agent to reboot the guest
+	AGENT
+	// RESET resets the host, as the reset button of a physical machine
+	RESET
+)
+
+// Parse returns the reboot mode named str (case insensitive)
+func Parse(str string) (Enum, error) {
+	for mode := ACPI; mode <= RESET; mode++ {
+		if strings.EqualFold(mode.String(), str) {
+			return mode, nil
+		}
+	}
+	return ACPI, fmt.Errorf("Unknown reboot mode '%s'", str)
+}
@@ -32,4 +32,10 @@ const (
 	STOPPING
 	// ERROR when host is in error state*/
 	ERROR
+	// PAUSED when host execution is suspended, its memory being kept
+	PAUSED
+	// SUSPENDED when host is suspended to RAM or to disk
+	SUSPENDED
+	// CRASHED when host has crashed
+	CRASHED
 )
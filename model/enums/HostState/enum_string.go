@@ -4,9 +4,9 @@ package HostState
 
 import "strconv"
 
-const _Enum_name = "STOPPEDSTARTINGSTARTEDSTOPPINGERROR"
+const _Enum_name = "STOPPEDSTARTINGSTARTEDSTOPPINGERRORPAUSEDSUSPENDEDCRASHED"
 
-var _Enum_index = [...]uint8{0, 7, 15, 22, 30, 35}
+var _Enum_index = [...]uint8{0, 7, 15, 22, 30, 35, 41, 50, 57}
 
 func (i Enum) String() string {
 	if i < 0 || i >= Enum(len(_Enum_index)-1) {
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package IPv6Mode
+
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate stringer -type=Enum
+
+//Enum represents the way the hosts of a network get their IPv6 addresses
+type Enum int
+
+const (
+	// DHCP leases the addresses with a stateful DHCPv6 server
+	DHCP Enum = iota
+	// SLAAC lets the hosts build their addresses from the router advertisements (the prefix must be a /64)
+	SLAAC
+)
+
+// Parse returns the IPv6 mode named str (case insensitive)
+func Parse(str string) (Enum, error) {
+	for mode := DHCP; mode <= SLAAC; mode++ {
+		if strings.EqualFold(mode.String(), str) {
+			return mode, nil
+		}
+	}
+	return DHCP, fmt.Errorf("Unknown IPv6 mode '%s'", str)
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package NetworkMode
+
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate stringer -type=Enum
+
+//Enum represents the way the traffic of a network is forwarded outside of the hypervisor
+type Enum int
+
+const (
+	// ISOLATED networks only reach the hypervisor and the other hosts of the network
+	ISOLATED Enum = iota
+	// NAT networks reach the outside through the hypervisor, their addresses being translated into the ones of the hypervisor
+	NAT
+	// ROUTE networks are routed by the hypervisor without address translation, the outside needs a route back to them
+	ROUTE
+	// BRIDGE networks are plugged on an existing bridge of the hypervisor, libvirt manages no address on them
+	BRIDGE
+	// OPEN networks are routed by the hypervisor without any firewall rule added by libvirt
+	OPEN
+)
+
+// Parse returns the network mode named str (case insensitive)
+func Parse(str string) (Enum, error) {
+	for mode := ISOLATED; mode <= OPEN; mode++ {
+		if strings.EqualFold(mode.String(), str) {
+			return mode, nil
+		}
+	}
+	return ISOLATED, fmt.Errorf("Unknown network mode '%s'", str)
+}
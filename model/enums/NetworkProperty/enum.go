@@ -21,4 +21,8 @@ const (
 	DescriptionV1 = "1"
 	// HostsV1 contains list of hosts attached to the network
 	HostsV1 = "2"
+	// IPAMV1 contains the subnets and the addresses management of the network
+	IPAMV1 = "3"
+	// PortForwardsV1 contains the ports of the hosts of the network forwarded from the gateway or the hypervisor
+	PortForwardsV1 = "4"
 )
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package PortForwardMode
+
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate stringer -type=Enum
+
+//Enum represents where the port of a private host is forwarded from
+type Enum int
+
+const (
+	// GATEWAY forwards a port of the gateway of the network of the host (DNAT rule on the gateway over SSH)
+	GATEWAY Enum = iota
+	// HYPERVISOR forwards a port of the hypervisor (DNAT rule in nftables), the network must be routed (route or open mode)
+	HYPERVISOR
+)
+
+// Parse returns the port forward mode named str (case insensitive)
+func Parse(str string) (Enum, error) {
+	for mode := GATEWAY; mode <= HYPERVISOR; mode++ {
+		if strings.EqualFold(mode.String(), str) {
+			return mode, nil
+		}
+	}
+	return GATEWAY, fmt.Errorf("Unknown port forward mode '%s'", str)
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package RuleDirection
+
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate stringer -type=Enum
+
+//Enum represents the direction of the traffic a security group rule applies to
+type Enum int
+
+const (
+	// INGRESS is the traffic entering the host
+	INGRESS Enum = iota
+	// EGRESS is the traffic leaving the host
+	EGRESS
+)
+
+// Parse returns the rule direction named str (case insensitive)
+func Parse(str string) (Enum, error) {
+	for direction := INGRESS; direction <= EGRESS; direction++ {
+		if strings.EqualFold(direction.String(), str) {
+			return direction, nil
+		}
+	}
+	return INGRESS, fmt.Errorf("Unknown rule direction '%s'", str)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package RuleProtocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate stringer -type=Enum
+
+//Enum represents the protocol of the traffic a security group rule applies to
+type Enum int
+
+const (
+	// ALL matches any IP traffic
+	ALL Enum = iota
+	// TCP matches the TCP traffic, optionally restricted to a port range
+	TCP
+	// UDP matches the UDP traffic, optionally restricted to a port range
+	UDP
+	// ICMP matches the ICMP traffic (ICMPv6 for an IPv6 rule)
+	ICMP
+)
+
+// Parse returns the rule protocol named str (case insensitive)
+func Parse(str string) (Enum, error) {
+	for protocol := ALL; protocol <= ICMP; protocol++ {
+		if strings.EqualFold(protocol.String(), str) {
+			return protocol, nil
+		}
+	}
+	return ALL, fmt.Errorf("Unknown rule protocol '%s'", str)
+}
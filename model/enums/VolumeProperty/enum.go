@@ -21,4 +21,6 @@ const (
 	DescriptionV1 = "1"
 	// AttachedV1 contains additional information about hosts attaching the volume
 	AttachedV1 = "2"
+	// DescriptionV2 specifies optional additional info describing volume (purpose, parent of a snapshot or a clone, ...)
+	DescriptionV2 = "3"
 )
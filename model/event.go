@@ -0,0 +1,46 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+
+	"github.com/CS-SI/LocalDriver/model/enums/HostState"
+)
+
+const (
+	// EventHost is the resource type of the events about hosts
+	EventHost = "host"
+	// EventNetwork is the resource type of the events about networks
+	EventNetwork = "network"
+	// EventStoragePool is the resource type of the events about storage pools
+	EventStoragePool = "storage-pool"
+)
+
+// Event represents a change of a resource notified by the hypervisor
+type Event struct {
+	Time            time.Time      `json:"time"`
+	ResourceType    string         `json:"resource_type"`               // contains the type of the resource (host, network or storage-pool)
+	ResourceID      string         `json:"resource_id,omitempty"`       // contains the ID of the resource
+	ResourceName    string         `json:"resource_name"`               // contains the name of the resource
+	Event           string         `json:"event"`                       // describes what happened (ex: started, stopped, crashed)
+	Detail          string         `json:"detail,omitempty"`            // describes why it happened (ex: booted, destroyed, panicked)
+	HostState       HostState.Enum `json:"-"`                           // contains the state of the host after the event (host events only)
+	StateName       string         `json:"host_state,omitempty"`        // contains the name of HostState (host events only)
+	StateReason     string         `json:"state_reason,omitempty"`      // describes the state of the host after the event (host events only)
+	StateReasonCode int            `json:"state_reason_code,omitempty"` // reason code of the hypervisor for the state of the host (host events only)
+}
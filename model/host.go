@@ -17,7 +17,10 @@
 package model
 
 import (
+	"time"
+
 	"github.com/CS-SI/LocalDriver/model/enums/HostProperty"
+	"github.com/CS-SI/LocalDriver/model/enums/HostRebootMode"
 	"github.com/CS-SI/LocalDriver/model/enums/HostState"
 	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
 )
@@ -73,6 +76,18 @@ type Image struct {
 	Name string `json:"name,omitempty"`
 }
 
+// ImageCaptureRequest represents requirements to capture the disk of a host as a new image
+type ImageCaptureRequest struct {
+	// HostID is the name or the ID of the host to capture
+	HostID string
+	// Name is the name of the new image
+	Name string
+	// Shutdown a flag telling if the host must be stopped during the capture (restarted afterwards)
+	Shutdown bool
+	// Sysprep a flag telling if the captured disk must be reset (machine-id, SSH host keys, userdata artifacts)
+	Sysprep bool
+}
+
 // HostRequest represents requirements to create host
 type HostRequest struct {
 	// ResourceName contains the name of the compute resource
@@ -91,6 +106,42 @@ type HostRequest struct {
 	ImageID string
 	// KeyPair is the (optional) specific KeyPair to use (if not provided, a new KeyPair will be generated)
 	KeyPair *KeyPair
+	// IPAddresses contains the (optional) IPv4 addresses the host must have, indexed by network ID
+	IPAddresses map[string]string
+}
+
+// HostStopRequest represents requirements to stop a host
+type HostStopRequest struct {
+	// HostID is the name or the ID of the host to stop
+	HostID string
+	// Timeout is the time given to the host to stop gracefully (0 to return without waiting)
+	Timeout time.Duration
+	// Force a flag telling if the host must be powered off when Timeout is reached
+	Force bool
+}
+
+// HostRebootRequest represents requirements to reboot a host
+type HostRebootRequest struct {
+	// HostID is the name or the ID of the host to reboot
+	HostID string
+	// Mode is the way the host is asked to reboot
+	Mode HostRebootMode.Enum
+	// Timeout is the time given to the host to reboot gracefully (0 to return without waiting)
+	Timeout time.Duration
+	// Force a flag telling if the host must be reset when Timeout is reached
+	Force bool
+}
+
+// HostNetworkRequest represents requirements to plug a host on a network, or to unplug it
+type HostNetworkRequest struct {
+	// HostID is the name or the ID of the host
+	HostID string
+	// NetworkID is the name or the ID of the network
+	NetworkID string
+	// IPAddress is the IPv4 address reserved for the host on the network, leased by the DHCP if empty (plugging only)
+	IPAddress string
+	// VLANs override the VLAN tags of the network for the port of the host, several tags make a trunk port (plugging only)
+	VLANs []int
 }
 
 // HostSize ...
@@ -105,11 +156,13 @@ type HostTemplate struct {
 
 // Host contains the information about a host
 type Host struct {
-	ID         string         `json:"id,omitempty"`
-	Name       string         `json:"name,omitempty"`
-	LastState  HostState.Enum `json:"state,omitempty"`
-	PrivateKey string         `json:"private_key,omitempty"`
-	Properties *Extensions    `json:"properties,omitempty"`
+	ID                  string         `json:"id,omitempty"`
+	Name                string         `json:"name,omitempty"`
+	LastState           HostState.Enum `json:"state,omitempty"`
+	LastStateReason     string         `json:"state_reason,omitempty"`      // describes the state of the hypervisor and its reason (ex: "shut off (crashed)")
+	LastStateReasonCode int            `json:"state_reason_code,omitempty"` // reason code of the hypervisor, its meaning depends on the state
+	PrivateKey          string         `json:"private_key,omitempty"`
+	Properties          *Extensions    `json:"properties,omitempty"`
 }
 
 // NewHost ...
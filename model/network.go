@@ -18,6 +18,8 @@ package model
 
 import (
 	"github.com/CS-SI/LocalDriver/model/enums/IPVersion"
+	"github.com/CS-SI/LocalDriver/model/enums/IPv6Mode"
+	"github.com/CS-SI/LocalDriver/model/enums/NetworkMode"
 )
 
 // GatewayRequest to create a Gateway into a network
@@ -41,18 +43,41 @@ type NetworkRequest struct {
 	IPVersion IPVersion.Enum
 	// CIDR mask
 	CIDR string
-	// DNSServers
+	// IPv6CIDR is the IPv6 prefix of a dual-stack network (IPVersion IPv4), for an IPv6 only network it defaults to CIDR
+	IPv6CIDR string
+	// IPv6Mode tells how the hosts get their IPv6 addresses (see IPv6Mode)
+	IPv6Mode IPv6Mode.Enum
+	// DNSServers are the upstream DNS servers the requests outside of DNSDomain are forwarded to
 	DNSServers []string
+	// DNSDomain is the domain of the names of the hosts of the network, defaults to <Name>.local
+	DNSDomain string
+	// Mode tells how the traffic of the network is forwarded outside of the hypervisor (see NetworkMode)
+	Mode NetworkMode.Enum
+	// Bridge is the name of the existing bridge of the hypervisor a BRIDGE network is plugged on
+	Bridge string
+	// VLANs are the VLAN tags of the ports of the hosts of a BRIDGE network plugged on an Open vSwitch bridge,
+	// several tags make trunk ports
+	VLANs []int
 }
 
 // Network representes a virtual network
 type Network struct {
-	ID         string         `json:"id,omitempty"`         // ID for the network (from provider)
-	Name       string         `json:"name,omitempty"`       // Name of the network
-	CIDR       string         `json:"mask,omitempty"`       // network in CIDR notation
-	GatewayID  string         `json:"gateway_id,omitempty"` // contains the id of the host acting as gateway for the network
-	IPVersion  IPVersion.Enum `json:"ip_version,omitempty"` // IPVersion is IPv4 or IPv6 (see IPVersion)
-	Properties *Extensions    `json:"properties,omitempty"` // contains optional supplemental information
+	ID                 string           `json:"id,omitempty"`                   // ID for the network (from provider)
+	Name               string           `json:"name,omitempty"`                 // Name of the network
+	CIDR               string           `json:"mask,omitempty"`                 // network in CIDR notation
+	GatewayID          string           `json:"gateway_id,omitempty"`           // contains the id of the host acting as gateway for the network
+	SecondaryGatewayID string           `json:"secondary_gateway_id,omitempty"` // contains the id of the backup gateway of a network with highly available gateways
+	GatewayVIP         string           `json:"gateway_vip,omitempty"`          // virtual IP of the highly available gateways, held by the master one
+	IPVersion          IPVersion.Enum   `json:"ip_version,omitempty"`           // IPVersion is IPv4 or IPv6 (see IPVersion)
+	IPv6CIDR           string           `json:"mask_v6,omitempty"`              // IPv6 prefix of the network in CIDR notation, empty if the network has no IPv6
+	IPv6Mode           IPv6Mode.Enum    `json:"ipv6_mode,omitempty"`            // IPv6Mode tells how the hosts get their IPv6 addresses (see IPv6Mode)
+	DNSDomain          string           `json:"dns_domain,omitempty"`           // domain of the names of the hosts of the network
+	DNSServers         []string         `json:"dns_servers,omitempty"`          // upstream DNS servers the other requests are forwarded to
+	Mode               NetworkMode.Enum `json:"mode,omitempty"`                 // Mode tells how the traffic is forwarded outside of the hypervisor (see NetworkMode)
+	Bridge             string           `json:"bridge,omitempty"`               // name of the bridge of the hypervisor the network is plugged on
+	VirtualPort        string           `json:"virtualport,omitempty"`          // type of the ports of the hosts on the bridge (openvswitch), empty for a Linux bridge
+	VLANs              []int            `json:"vlans,omitempty"`                // VLAN tags of the ports of the hosts, several tags make trunk ports
+	Properties         *Extensions      `json:"properties,omitempty"`           // contains optional supplemental information
 }
 
 // NewNetwork ...
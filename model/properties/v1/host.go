@@ -296,3 +296,34 @@ func NewHostFeatures() *HostFeatures {
 		Installed: map[string]*HostInstalledFeature{},
 	}
 }
+
+// HostHibernation contains information about the state of the host saved on disk (libvirt managed save)
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental fields
+type HostHibernation struct {
+	StateFile string    `json:"state_file,omitempty"` // contains the path of the managed save image holding the state of the host (empty if not hibernated)
+	SavedAt   time.Time `json:"saved_at,omitempty"`   // tells when the state of the host has been saved
+}
+
+// NewHostHibernation ...
+func NewHostHibernation() *HostHibernation {
+	return &HostHibernation{}
+}
+
+// HostSecurityGroups contains information about the security groups applied to the host
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental fields
+type HostSecurityGroups struct {
+	ByID   map[string]string `json:"by_id,omitempty"`   // names of the security groups applied to the host, indexed by ID
+	ByName map[string]string `json:"by_name,omitempty"` // IDs of the security groups applied to the host, indexed by name
+}
+
+// NewHostSecurityGroups ...
+func NewHostSecurityGroups() *HostSecurityGroups {
+	return &HostSecurityGroups{
+		ByID:   map[string]string{},
+		ByName: map[string]string{},
+	}
+}
@@ -18,6 +18,9 @@ package propertiesv1
 
 import (
 	"time"
+
+	"github.com/CS-SI/LocalDriver/model/enums/PortForwardMode"
+	"github.com/CS-SI/LocalDriver/model/enums/RuleProtocol"
 )
 
 // NetworkDescription contains additional information describing the network, in V1
@@ -47,3 +50,61 @@ func NewNetworkHosts() *NetworkHosts {
 		ByName: map[string]string{},
 	}
 }
+
+// NetworkSubnet contains the layout of a subnet of the network
+type NetworkSubnet struct {
+	CIDR      string `json:"cidr,omitempty"`       // subnet in CIDR notation
+	Gateway   string `json:"gateway,omitempty"`    // address of the hypervisor in the subnet
+	PoolStart string `json:"pool_start,omitempty"` // first address leased by DHCP, empty without DHCP
+	PoolEnd   string `json:"pool_end,omitempty"`   // last address leased by DHCP, empty without DHCP
+}
+
+// NetworkIPAM contains the addresses management of the network, in V1
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental fields
+type NetworkIPAM struct {
+	Subnets   []*NetworkSubnet  `json:"subnets,omitempty"`    // IPv4 and IPv6 subnets of the network
+	Reserved  map[string]string `json:"reserved,omitempty"`   // addresses kept out of use, placeholder MAC address indexed by address
+	Static    map[string]string `json:"static,omitempty"`     // addresses reserved for an interface of a host, MAC address indexed by address
+	Allocated map[string]string `json:"allocated,omitempty"`  // addresses leased by DHCP, MAC address indexed by address
+	UpdatedAt time.Time         `json:"updated_at,omitempty"` // date of the last synchronization with the hypervisor
+}
+
+// NewNetworkIPAM ...
+func NewNetworkIPAM() *NetworkIPAM {
+	return &NetworkIPAM{
+		Subnets:   []*NetworkSubnet{},
+		Reserved:  map[string]string{},
+		Static:    map[string]string{},
+		Allocated: map[string]string{},
+	}
+}
+
+// NetworkPortForward contains a port of a host of the network forwarded from a public port
+type NetworkPortForward struct {
+	ID          string               `json:"id,omitempty"`           // identifies the forward : <mode>-<protocol>-<public port>
+	Mode        PortForwardMode.Enum `json:"mode"`                   // tells if the public port is a port of the gateway or of the hypervisor
+	Protocol    RuleProtocol.Enum    `json:"protocol"`               // TCP or UDP
+	PublicPort  int                  `json:"public_port,omitempty"`  // forwarded port of the gateway or of the hypervisor
+	HostID      string               `json:"host_id,omitempty"`      // ID of the host the port is forwarded to
+	HostName    string               `json:"host_name,omitempty"`    // name of the host the port is forwarded to
+	HostIP      string               `json:"host_ip,omitempty"`      // address of the host on the network
+	PrivatePort int                  `json:"private_port,omitempty"` // port of the host the traffic is forwarded to
+	GatewayID   string               `json:"gateway_id,omitempty"`   // ID of the gateway holding the rule (GATEWAY mode only)
+}
+
+// NetworkPortForwards contains the port forwards to the hosts of the network, in V1
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental fields
+type NetworkPortForwards struct {
+	ByID map[string]*NetworkPortForward `json:"by_id,omitempty"` // port forwards indexed by ID
+}
+
+// NewNetworkPortForwards ...
+func NewNetworkPortForwards() *NetworkPortForwards {
+	return &NetworkPortForwards{
+		ByID: map[string]*NetworkPortForward{},
+	}
+}
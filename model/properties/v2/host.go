@@ -0,0 +1,41 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv2
+
+import (
+	"time"
+
+	propsv1 "github.com/CS-SI/LocalDriver/model/properties/v1"
+)
+
+// HostNetwork contains network information related to Host, in V2
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental fields
+type HostNetwork struct {
+	*propsv1.HostNetwork
+	AddressSources map[string]string `json:"address_sources,omitempty"` // contains the name of the source which resolved each IP address (indexed by IP address)
+	ResolvedAt     time.Time         `json:"resolved_at,omitempty"`     // tells when the IP addresses have been resolved
+}
+
+// NewHostNetwork returns a blank HostNetwork
+func NewHostNetwork() *HostNetwork {
+	return &HostNetwork{
+		HostNetwork:    propsv1.NewHostNetwork(),
+		AddressSources: map[string]string{},
+	}
+}
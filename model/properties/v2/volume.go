@@ -0,0 +1,38 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package propertiesv2
+
+import (
+	"time"
+)
+
+// VolumeDescription contains additional information describing the volume, in V2
+// not FROZEN yet
+// Note: if tagged as FROZEN, must not be changed ever.
+//       Create a new version instead with needed supplemental fields
+type VolumeDescription struct {
+	Purpose    string    `json:"purpose,omitempty"`     // contains the reason of the existence of the volume
+	Created    time.Time `json:"created,omitempty"`     // contains the time of creation of the volume
+	IsSnapshot bool      `json:"is_snapshot,omitempty"` // tells if the volume is a snapshot of another volume
+	ParentID   string    `json:"parent_id,omitempty"`   // contains the ID of the volume (or snapshot) this volume has been made from
+	ParentName string    `json:"parent_name,omitempty"` // contains the name of the volume (or snapshot) this volume has been made from
+}
+
+// NewVolumeDescription ...
+func NewVolumeDescription() *VolumeDescription {
+	return &VolumeDescription{}
+}
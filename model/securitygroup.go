@@ -0,0 +1,76 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"github.com/CS-SI/LocalDriver/model/enums/RuleDirection"
+	"github.com/CS-SI/LocalDriver/model/enums/RuleProtocol"
+)
+
+// SecurityGroupRequest represents a security group request
+type SecurityGroupRequest struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SecurityGroupRule represents a rule allowing some traffic of the hosts a security group is applied to
+type SecurityGroupRule struct {
+	Direction RuleDirection.Enum `json:"direction"`           // Direction tells if the rule applies to the traffic entering or leaving the hosts
+	Protocol  RuleProtocol.Enum  `json:"protocol"`            // Protocol of the allowed traffic (see RuleProtocol)
+	PortFrom  int                `json:"port_from,omitempty"` // first port of the allowed range (TCP and UDP only, 0 for any port)
+	PortTo    int                `json:"port_to,omitempty"`   // last port of the allowed range, defaults to PortFrom
+	// CIDR is the source (INGRESS) or destination (EGRESS) of the allowed traffic, empty for any address
+	CIDR string `json:"cidr,omitempty"`
+	// SourceGroupID allows the traffic from (INGRESS) or to (EGRESS) the hosts of another security group instead of CIDR
+	SourceGroupID string `json:"source_group_id,omitempty"`
+}
+
+// SecurityGroup represents a named set of rules filtering the traffic of hosts
+// Everything not allowed by a rule is dropped, except the egress traffic of a group without EGRESS rule
+type SecurityGroup struct {
+	ID          string               `json:"id,omitempty"`
+	Name        string               `json:"name,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Rules       []*SecurityGroupRule `json:"rules,omitempty"`
+	// Hosts contains the names of the hosts the security group is applied to, indexed by host ID
+	Hosts map[string]string `json:"hosts,omitempty"`
+}
+
+// NewSecurityGroup ...
+func NewSecurityGroup() *SecurityGroup {
+	return &SecurityGroup{
+		Rules: []*SecurityGroupRule{},
+		Hosts: map[string]string{},
+	}
+}
+
+// Serialize serializes SecurityGroup instance into bytes (output json code)
+func (sg *SecurityGroup) Serialize() ([]byte, error) {
+	return SerializeToJSON(sg)
+}
+
+// Deserialize reads json code and restores a SecurityGroup
+func (sg *SecurityGroup) Deserialize(buf []byte) error {
+	err := DeserializeFromJSON(buf, sg)
+	if err != nil {
+		return err
+	}
+	if sg.Hosts == nil {
+		sg.Hosts = map[string]string{}
+	}
+	return nil
+}
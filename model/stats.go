@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"time"
+)
+
+// HostDiskStats contains the I/O counters of a disk of a host
+type HostDiskStats struct {
+	Name       string `json:"name"`
+	ReadBytes  uint64 `json:"read_bytes"`
+	ReadOps    uint64 `json:"read_ops"`
+	WriteBytes uint64 `json:"write_bytes"`
+	WriteOps   uint64 `json:"write_ops"`
+}
+
+// HostInterfaceStats contains the traffic counters of a network interface of a host
+type HostInterfaceStats struct {
+	Name      string `json:"name"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDrops   uint64 `json:"rx_drops"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDrops   uint64 `json:"tx_drops"`
+}
+
+// HostStats contains the runtime statistics of a host, sampled at Time
+type HostStats struct {
+	HostID         string               `json:"host_id"`
+	HostName       string               `json:"host_name"`
+	Time           time.Time            `json:"time"`
+	Active         bool                 `json:"active"`
+	VCPUs          int                  `json:"vcpus"`
+	CPUTime        uint64               `json:"cpu_time_ns"`                 // contains the CPU time consumed by the host since its start (in nanoseconds)
+	CPUUtilisation float64              `json:"cpu_utilisation,omitempty"`   // contains the percentage of the vCPUs used since the previous sample (see ComputeCPUUtilisation)
+	MemoryBalloon  uint64               `json:"memory_balloon_kib"`          // contains the memory currently given to the host (in KiB)
+	MemoryMaximum  uint64               `json:"memory_maximum_kib"`          // contains the maximal memory of the host (in KiB)
+	MemoryRSS      uint64               `json:"memory_rss_kib"`              // contains the memory used by the host on the hypervisor (in KiB)
+	MemoryUnused   uint64               `json:"memory_unused_kib,omitempty"` // contains the memory left unused by the guest, known with the balloon driver only (in KiB)
+	Disks          []HostDiskStats      `json:"disks,omitempty"`
+	Interfaces     []HostInterfaceStats `json:"interfaces,omitempty"`
+}
+
+// ComputeCPUUtilisation sets CPUUtilisation from the CPU time consumed since previous, a sample of the same host
+func (stats *HostStats) ComputeCPUUtilisation(previous *HostStats) {
+	if previous == nil || stats.VCPUs == 0 || stats.CPUTime < previous.CPUTime {
+		stats.CPUUtilisation = 0
+		return
+	}
+	elapsed := stats.Time.Sub(previous.Time)
+	if elapsed <= 0 {
+		stats.CPUUtilisation = 0
+		return
+	}
+	stats.CPUUtilisation = float64(stats.CPUTime-previous.CPUTime) / float64(elapsed.Nanoseconds()) / float64(stats.VCPUs) * 100
+}
@@ -28,6 +28,18 @@ type VolumeRequest struct {
 	Speed VolumeSpeed.Enum `json:"speed,omitempty"`
 }
 
+// VolumeSnapshotRequest represents a volume snapshot request
+type VolumeSnapshotRequest struct {
+	Name     string `json:"name,omitempty"`
+	VolumeID string `json:"volume_id,omitempty"`
+}
+
+// VolumeCloneRequest represents a request to create a volume from a snapshot
+type VolumeCloneRequest struct {
+	Name       string `json:"name,omitempty"`
+	SnapshotID string `json:"snapshot_id,omitempty"`
+}
+
 // Volume represents a block volume
 type Volume struct {
 	ID    string           `json:"id,omitempty"`
@@ -0,0 +1,55 @@
+#!/usr/bin/env bash
+#
+# Copyright 2018, CS Systemes d'Information, http://www.c-s.fr
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# block_device_resize.sh
+# Grows the last partition (if any) and the filesystem of a block device to the new size of the device
+
+set -u -o pipefail
+
+function print_error {
+    read line file <<<$(caller)
+    echo "An error occurred in line $line of file $file:" "{"`sed "${line}q;d" "$file"`"}" >&2
+}
+trap print_error ERR
+
+DEVICE="{{.Device}}"
+
+# Makes sure the kernel sees the new size of the device
+echo 1 >/sys/class/block/$(basename $DEVICE)/device/rescan 2>/dev/null || true
+
+PARTITION=$(lsblk -lnpo NAME,TYPE "$DEVICE" | awk '$2 == "part" { print $1 }' | tail -n1)
+if [ ! -z "$PARTITION" ]; then
+    which growpart &>/dev/null || {
+        echo "growpart is required to grow partition $PARTITION"
+        exit 1
+    }
+    growpart "$DEVICE" $(echo $PARTITION | grep -o '[0-9]*$') || exit $?
+    DEVICE=$PARTITION
+fi
+
+FILESYSTEM=$(lsblk -lnpo FSTYPE "$DEVICE" | head -n1)
+case $FILESYSTEM in
+    ext2|ext3|ext4)
+        resize2fs "$DEVICE"
+        ;;
+    xfs)
+        xfs_growfs "$(findmnt -nro TARGET --source $DEVICE | head -n1)"
+        ;;
+    *)
+        echo "Unsupported filesystem '$FILESYSTEM' on $DEVICE"
+        exit 1
+        ;;
+esac
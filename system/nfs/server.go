@@ -70,6 +70,15 @@ func (s *Server) UnmountBlockDevice(device string) error {
 	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to umount block device")
 }
 
+// ResizeBlockDevice grows the partition and the filesystem of a block device to the size of the device on the remote system
+func (s *Server) ResizeBlockDevice(device string) error {
+	data := map[string]interface{}{
+		"Device": device,
+	}
+	retcode, stdout, stderr, err := executeScript(*s.SshConfig, "block_device_resize.sh", data)
+	return handleExecuteScriptReturn(retcode, stdout, stderr, err, "Error executing script to resize block device")
+}
+
 // AddShare configures a local path to be exported by NFS
 func (s *Server) AddShare(path string, acl string) error {
 	data := map[string]interface{}{
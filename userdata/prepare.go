@@ -21,11 +21,14 @@ package userdata
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"strings"
 	"text/template"
 
 	"github.com/CS-SI/LocalDriver/api"
 	"github.com/CS-SI/LocalDriver/model"
+	"github.com/CS-SI/LocalDriver/model/enums/IPVersion"
+	"github.com/CS-SI/LocalDriver/model/enums/IPv6Mode"
 	rice "github.com/GeertJohan/go.rice"
 )
 
@@ -46,8 +49,16 @@ type userData struct {
 	// DNSServers contains the list of DNS servers to use
 	// Used only if IsGateway is true
 	DNSServers []string
+	// DNSDomain is the search domain of the host, resolved by the network
+	DNSDomain string
 	//CIDR contains the cidr of the network
 	CIDR string
+	// IPv6, if set to true, configure IPv6 on the interfaces
+	IPv6 bool
+	// DHCPv6, if set to true, IPv6 addresses are leased by DHCPv6 instead of being built from router advertisements (SLAAC)
+	DHCPv6 bool
+	// IPv6CIDR contains the IPv6 prefix of the network
+	IPv6CIDR string
 	// GatewayIP is the IP of the gateway
 	GatewayIP string
 	// Password for the user gpac (for troubleshoot use, useable only in console)
@@ -87,6 +98,9 @@ func Prepare(
 	ip := ""
 	if request.DefaultGateway != nil {
 		ip = request.DefaultGateway.GetPrivateIP()
+		if vip := request.Networks[0].GatewayVIP; vip != "" {
+			ip = vip
+		}
 	}
 
 	config, err := client.GetCfgOpts()
@@ -123,6 +137,32 @@ func Prepare(
 		}
 	}
 
+	dnsDomain := ""
+	if network := request.Networks[0]; network.DNSDomain != "" {
+		dnsDomain = network.DNSDomain
+		// The hypervisor resolves the names of the hosts of the network and forwards the other requests
+		resolver := net.ParseIP(strings.Split(network.CIDR, "/")[0])
+		if resolver != nil {
+			if network.IPVersion == IPVersion.IPv6 {
+				// The CIDR of an IPv6 network is its prefix, the hypervisor has the first address
+				resolver[len(resolver)-1]++
+			}
+			dnsList = []string{resolver.String()}
+		}
+	}
+
+	ipv6, dhcpv6, ipv6CIDR := false, false, ""
+	for _, network := range request.Networks {
+		if network.IPv6CIDR == "" {
+			continue
+		}
+		if !ipv6 {
+			ipv6CIDR = network.IPv6CIDR
+		}
+		ipv6 = true
+		dhcpv6 = dhcpv6 || network.IPv6Mode == IPv6Mode.DHCP
+	}
+
 	data := userData{
 		User:       model.DefaultUser,
 		PublicKey:  strings.Trim(kp.PublicKey, "\n"),
@@ -131,7 +171,11 @@ func Prepare(
 		IsGateway:  request.DefaultGateway == nil && request.Networks[0].Name != model.SingleHostNetworkName && !useLayer3Networking,
 		AddGateway: !request.PublicIP && !useLayer3Networking,
 		DNSServers: dnsList,
+		DNSDomain:  dnsDomain,
 		CIDR:       cidr,
+		IPv6:       ipv6,
+		DHCPv6:     dhcpv6,
+		IPv6CIDR:   ipv6CIDR,
 		GatewayIP:  ip,
 		Password:   gpacPassword,
 		PublicIp:   "172.26.128",
@@ -85,6 +85,12 @@ sfSaveIptablesRules() {
        rhel|centos) iptables-save >/etc/sysconfig/iptables;;
        debian|ubuntu) iptables-save >/etc/iptables/rules.v4;;
    esac
+{{- if .IPv6 }}
+   case $LINUX_KIND in
+       rhel|centos) ip6tables-save >/etc/sysconfig/ip6tables;;
+       debian|ubuntu) ip6tables-save >/etc/iptables/rules.v6;;
+   esac
+{{- end }}
 }
 
 create_user() {
@@ -150,6 +156,9 @@ configure_network_debian() {
         if [ $IF != "lo" ]; then
             echo "auto ${IF}" >>$cfg
             echo "iface ${IF} inet dhcp" >>$cfg
+{{- if .IPv6 }}
+            echo "iface ${IF} inet6 {{ if .DHCPv6 }}dhcp{{ else }}auto{{ end }}" >>$cfg
+{{- end }}
         fi
     done
 
@@ -170,8 +179,16 @@ network:
   ethernets:
     ens3:
       dhcp4: true
+{{- if .IPv6 }}
+      dhcp6: {{ .DHCPv6 }}
+      accept-ra: true
+{{- end }}
     ens4:
       dhcp4: true
+{{- if .IPv6 }}
+      dhcp6: {{ .DHCPv6 }}
+      accept-ra: true
+{{- end }}
 {{- if .GatewayIP }}
       gateway4: {{.GatewayIP}}
 {{- end }}
@@ -199,6 +216,13 @@ configure_network_redhat() {
 DEVICE=$IF
 BOOTPROTO=dhcp
 ONBOOT=yes
+{{- if .IPv6 }}
+IPV6INIT=yes
+IPV6_AUTOCONF=yes
+{{- if .DHCPv6 }}
+DHCPV6C=yes
+{{- end }}
+{{- end }}
 EOF
         fi
     done
@@ -304,6 +328,11 @@ configure_as_gateway() {
             mv -f ${i}.new ${i}
         done
         echo "net.ipv4.ip_forward=1" >/etc/sysctl.d/98-forward.conf
+{{- if .IPv6 }}
+        # Forwarding disables the router advertisements, unless accept_ra is 2
+        echo "net.ipv6.conf.all.forwarding=1" >>/etc/sysctl.d/98-forward.conf
+        echo "net.ipv6.conf.all.accept_ra=2" >>/etc/sysctl.d/98-forward.conf
+{{- end }}
         systemctl restart systemd-sysctl
 
         # Routing
@@ -313,6 +342,10 @@ configure_as_gateway() {
         iptables -t nat -A POSTROUTING -j MASQUERADE $o_PU_IF
         fw_f_accept $i_PR_IF $o_PU_IF -s {{ .CIDR }}
         fw_f_accept $i_PU_IF $o_PR_IF -m state --state RELATED,ESTABLISHED
+{{- if .IPv6 }}
+        ip6tables -A FORWARD -j ACCEPT $i_PR_IF $o_PU_IF -s {{ .IPv6CIDR }}
+        ip6tables -A FORWARD -j ACCEPT $i_PU_IF $o_PR_IF -m state --state RELATED,ESTABLISHED
+{{- end }}
     fi
 
     sfSaveIptablesRules
@@ -336,6 +369,9 @@ nameserver {{ . }}
 {{- else }}
 nameserver 1.1.1.1
 {{- end }}
+{{- if .DNSDomain }}
+search {{ .DNSDomain }}
+{{- end }}
 EOF
 }
 
@@ -350,6 +386,9 @@ nameserver {{ . }}
 {{- else }}
 nameserver 1.1.1.1
 {{- end }}
+{{- if .DNSDomain }}
+search {{ .DNSDomain }}
+{{- end }}
 EOF
     #rm -f /etc/resolvconf/resolv.conf.d/tail
     systemctl restart resolvconf
@@ -366,7 +405,11 @@ DNS={{ range .DNSServers }}{{ . }} {{ end }}
 DNS=1.1.1.1
 {{- end}}
 #FallbackDNS=
+{{- if .DNSDomain }}
+Domains={{ .DNSDomain }}
+{{- else }}
 #Domains=
+{{- end }}
 #LLMNR=no
 #MulticastDNS=no
 #DNSSEC=no